@@ -2,15 +2,26 @@ package main
 
 import (
 	"context"
+	"fmt"
 	"log"
 	"log/slog"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"sync"
+	"syscall"
 
 	"github.com/togdon/reply-bot/bot/pkg/bsky"
+	"github.com/togdon/reply-bot/bot/pkg/dedupe"
 	"github.com/togdon/reply-bot/bot/pkg/environment"
+	"github.com/togdon/reply-bot/bot/pkg/filesink"
 	"github.com/togdon/reply-bot/bot/pkg/gsheets"
 	"github.com/togdon/reply-bot/bot/pkg/mastodon"
+	"github.com/togdon/reply-bot/bot/pkg/outbox"
+	"github.com/togdon/reply-bot/bot/pkg/post"
+	"github.com/togdon/reply-bot/bot/pkg/rules"
+	"github.com/togdon/reply-bot/bot/pkg/spreadsheet"
+	"github.com/togdon/reply-bot/bot/pkg/sqlite"
 )
 
 func main() {
@@ -23,23 +34,60 @@ func main() {
 	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: logLevel}))
 
 	logger.Info("Successfully read the env", "log-level", logLevel)
-	logger.Info("Writing to sheet", "sheet", cfg.Google.SheetName)
 
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
-	writeChan := make(chan interface{})
+	// rules are applied before any stream starts, and later reloads swap the
+	// detectors in place without touching the streams
+	if cfg.Rules.File != "" {
+		watcher, err := rules.NewWatcher(logger, cfg.Rules.File, post.DefaultRegistry())
+		if err != nil {
+			log.Fatal(err)
+		}
+		watcher.Interval = cfg.Rules.PollInterval
+
+		hup := make(chan os.Signal, 1)
+		signal.Notify(hup, syscall.SIGHUP)
+		go watcher.Run(ctx, hup)
+	}
+
+	// buffered so that a slow write does not stall the streams; anything
+	// left in it at shutdown is drained by mastodonClient.Write
+	writeChan := make(chan interface{}, 64)
 
-	gsheetClient, err := gsheets.NewGSheetsClient(ctx, logger, []byte(cfg.Google.Credentials), gsheets.SHEET_ID, cfg.Google.SheetName)
+	fanOut, outboxes, err := newSink(ctx, logger, cfg)
 	if err != nil {
-		log.Fatalf("Unable to create gsheets client: %v", err)
-	} else {
-		logger.Debug("Successfully created gsheets client", "sheetID", gsheetClient.SheetID)
+		log.Fatal(err)
 	}
+
+	dedupeStore, err := newDedupeStore(ctx, logger, cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+	var store dedupe.Store
+	if dedupeStore != nil {
+		defer dedupeStore.Close()
+		store = dedupeStore
+	}
+
+	sink := dedupe.New(logger, fanOut, cfg.Dedupe.CacheSize, store)
+	defer sink.Close()
+
+	// everything writing to the sink must have stopped before it is closed
+	var wg sync.WaitGroup
+	for _, o := range outboxes {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			o.Run(ctx)
+		}()
+	}
+
 	mastodonClient, err := mastodon.NewClient(
 		logger,
 		writeChan,
-		gsheetClient,
+		sink,
 		mastodon.WithConfig(*cfg),
 	)
 	if err != nil {
@@ -50,8 +98,19 @@ func main() {
 
 	bskyClient := bsky.NewClient(
 		logger,
-		gsheetClient,
+		sink,
 	)
+	bskyClient.JetstreamURL = cfg.Bluesky.JetstreamURL
+	bskyClient.SearchQueries = cfg.Bluesky.SearchQueries
+	bskyClient.SearchInterval = cfg.Bluesky.SearchInterval
+	bskyClient.SearchWindow = cfg.Bluesky.SearchWindow
+	bskyClient.PDSURL = cfg.Bluesky.PDSURL
+	bskyClient.HTTPClient.Timeout = cfg.Bluesky.HTTPTimeout
+	if cfg.Bluesky.Identifier != "" {
+		if err := bskyClient.Login(ctx, cfg.Bluesky.Identifier, cfg.Bluesky.AppPassword); err != nil {
+			log.Fatal(err)
+		}
+	}
 
 	errs := make(chan error, 1)
 
@@ -64,17 +123,147 @@ func main() {
 	}()
 
 	go mastodonClient.Run(ctx, cancel, errs)
-	go mastodonClient.Write(ctx)
 
-	go bskyClient.Run(errs)
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		mastodonClient.Write(ctx)
+	}()
+	go func() {
+		defer wg.Done()
+		if cfg.Bluesky.Mode == environment.BskyJetstream {
+			bskyClient.Stream(ctx, errs)
+		} else {
+			bskyClient.Run(ctx, errs)
+		}
+	}()
+	if len(cfg.Bluesky.SearchQueries) > 0 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			bskyClient.Search(ctx, errs)
+		}()
+	}
 
 	for {
 		select {
 		case err := <-errs:
 			logger.Error("Processed error", "err", err)
 		case <-ctx.Done():
-			logger.Info("Shutting down...")
+			logger.Info("Shutting down...", "excluded", post.DefaultRegistry().Excluded())
+			wg.Wait()
 			return
 		}
 	}
 }
+
+// newSink creates every sink listed in cfg.Sinks behind a FanOut, so each
+// post is delivered to all of them and a failing sink does not hold up the
+// others. Unless disabled, each sink sits behind its own outbox so a post is
+// retried against only the sinks that failed; the returned outboxes must be
+// Run for anything to be delivered.
+func newSink(ctx context.Context, logger *slog.Logger, cfg *environment.Config) (*spreadsheet.FanOut, []*outbox.Outbox, error) {
+	var (
+		sinks    []spreadsheet.NamedSink
+		outboxes []*outbox.Outbox
+	)
+	for _, name := range cfg.Sinks {
+		sink, err := newNamedSink(ctx, logger, cfg, name)
+		if err == nil && cfg.Outbox.Dir != "" {
+			var o *outbox.Outbox
+			o, err = newOutbox(logger, cfg, name, sink)
+			if err != nil {
+				sink.Close()
+			} else {
+				outboxes = append(outboxes, o)
+				sink = o
+			}
+		}
+		if err != nil {
+			spreadsheet.NewFanOut(logger, 0, sinks...).Close()
+			return nil, nil, err
+		}
+		sinks = append(sinks, spreadsheet.NamedSink{Name: name, Sink: sink})
+	}
+
+	return spreadsheet.NewFanOut(logger, cfg.SinkTimeout, sinks...), outboxes, nil
+}
+
+// newDedupeStore returns the persistent store selected by cfg.Dedupe.Store,
+// or nil if duplicates should only be tracked in memory.
+func newDedupeStore(ctx context.Context, logger *slog.Logger, cfg *environment.Config) (*sqlite.Client, error) {
+	if cfg.Dedupe.Store == "" {
+		return nil, nil
+	}
+
+	store, err := sqlite.NewClient(ctx, logger, cfg.SQLite.Path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create sqlite dedupe store: %w", err)
+	}
+	logger.Info("Remembering seen posts in sqlite", "path", cfg.SQLite.Path)
+	return store, nil
+}
+
+func newOutbox(logger *slog.Logger, cfg *environment.Config, name string, sink spreadsheet.Sink) (*outbox.Outbox, error) {
+	o, err := outbox.New(logger, filepath.Join(cfg.Outbox.Dir, name), sink)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create %s outbox: %w", name, err)
+	}
+	o.MinBackoff = cfg.Outbox.MinBackoff
+	o.MaxBackoff = cfg.Outbox.MaxBackoff
+	o.WriteTimeout = cfg.SinkTimeout
+	if name == environment.SinkGSheets {
+		o.BatchSize = cfg.Google.BatchSize
+	}
+	return o, nil
+}
+
+func newNamedSink(ctx context.Context, logger *slog.Logger, cfg *environment.Config, name string) (spreadsheet.Sink, error) {
+	switch name {
+	case environment.SinkSQLite:
+		sqliteClient, err := sqlite.NewClient(ctx, logger, cfg.SQLite.Path)
+		if err != nil {
+			return nil, fmt.Errorf("unable to create sqlite client: %w", err)
+		}
+		logger.Info("Writing to sqlite", "path", cfg.SQLite.Path)
+		return sqliteClient, nil
+	case environment.SinkJSONL, environment.SinkCSV:
+		fileClient, err := filesink.NewClient(logger, cfg.File.Dir, cfg.File.Prefix, filesink.Format(name), cfg.File.MaxBytes, cfg.File.RotateDaily)
+		if err != nil {
+			return nil, fmt.Errorf("unable to create %s file sink: %w", name, err)
+		}
+		logger.Info("Writing to files", "dir", cfg.File.Dir, "format", name)
+		return fileClient, nil
+	default:
+		return newGSheetsClient(ctx, logger, cfg)
+	}
+}
+
+func newGSheetsClient(ctx context.Context, logger *slog.Logger, cfg *environment.Config) (*gsheets.Client, error) {
+	columns, err := gsheets.ParseColumns(cfg.Google.Columns)
+	if err != nil {
+		return nil, fmt.Errorf("invalid GOOGLE_COLUMNS: %w", err)
+	}
+
+	gsheetClient, err := gsheets.NewGSheetsClient(ctx, logger, []byte(cfg.Google.Credentials), cfg.Google.SheetID, cfg.Google.SheetName)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create gsheets client: %w", err)
+	}
+	gsheetClient.BatchSize = cfg.Google.BatchSize
+	gsheetClient.FlushInterval = cfg.Google.FlushInterval
+	gsheetClient.WriteTimeout = cfg.SinkTimeout
+	gsheetClient.PerType = cfg.Google.SheetPerType
+	gsheetClient.Columns = columns
+
+	// a custom layout is always checked; the default one may be in use on
+	// a sheet without a header row
+	if cfg.Google.ValidateHeader || cfg.Google.Columns != "" {
+		if err := gsheetClient.ValidateHeader(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	logger.Debug("Successfully created gsheets client", "sheetID", gsheetClient.SheetID, "batchSize", gsheetClient.BatchSize)
+	logger.Info("Writing to sheet", "sheet", cfg.Google.SheetName, "perType", cfg.Google.SheetPerType, "columns", len(columns))
+	return gsheetClient, nil
+}
@@ -1,18 +1,21 @@
 package bsky
 
 import (
+	"cmp"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
-	"log"
 	"log/slog"
 	"net/http"
 	"os"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
-	"github.com/togdon/reply-bot/bot/pkg/gsheets"
 	"github.com/togdon/reply-bot/bot/pkg/post"
+	"github.com/togdon/reply-bot/bot/pkg/spreadsheet"
 )
 
 const (
@@ -21,8 +24,53 @@ const (
 	feedsConfigFile = "bsky-feeds.json"
 )
 
+const (
+	facetLinkType = "app.bsky.richtext.facet#link"
+)
+
 type Record struct {
-	Text string `json:"text"`
+	Text   string   `json:"text"`
+	Langs  []string `json:"langs"`
+	Facets []Facet  `json:"facets"`
+	Embed  *Embed   `json:"embed"`
+}
+
+// Facet annotates a range of a record's text; link features carry the full
+// URL, where the text itself is often shortened for display.
+type Facet struct {
+	Features []FacetFeature `json:"features"`
+}
+
+type FacetFeature struct {
+	Type string `json:"$type"`
+	URI  string `json:"uri"`
+}
+
+// Embed decodes both the embeds stored in a record (app.bsky.embed.*) and
+// the hydrated views of them returned alongside a post in a feed
+// (app.bsky.embed.*#view), which share their field names.
+type Embed struct {
+	Type     string       `json:"$type"`
+	External *External    `json:"external"`
+	Record   *EmbedRecord `json:"record"`
+	Media    *Embed       `json:"media"`
+}
+
+// External is a link card.
+type External struct {
+	URI         string `json:"uri"`
+	Title       string `json:"title"`
+	Description string `json:"description"`
+}
+
+// EmbedRecord is a quoted post. In a record it is only a reference; in a
+// view it also holds the quoted record as Value and its embeds. Quotes with
+// media nest it one level deeper in Record.
+type EmbedRecord struct {
+	URI    string       `json:"uri"`
+	Value  *Record      `json:"value"`
+	Embeds []Embed      `json:"embeds"`
+	Record *EmbedRecord `json:"record"`
 }
 
 type BlueskyPost struct {
@@ -30,6 +78,8 @@ type BlueskyPost struct {
 	CID         string                 `json:"cid"`
 	Author      map[string]interface{} `json:"author"`
 	Record      Record                 `json:"record"`
+	Embed       *Embed                 `json:"embed"`
+	IndexedAt   time.Time              `json:"indexedAt"`
 	ReplyCount  int                    `json:"replyCount"`
 	RepostCount int                    `json:"reposeCount"`
 	QuoteCount  int                    `json:"quoteCount"`
@@ -49,39 +99,86 @@ type Feed struct {
 }
 
 type Client struct {
-	PollInterval       int
-	FeedsConfigFile    string
-	GoogleSheetsClient *gsheets.Client
-	Logger             *slog.Logger
+	PollInterval    int
+	FeedsConfigFile string
+	Sink            spreadsheet.Sink
+	Logger          *slog.Logger
+
+	// JetstreamURL is the subscribe endpoint Stream reads from, and
+	// ReconnectBackoff how long it first waits before reconnecting.
+	JetstreamURL     string
+	ReconnectBackoff time.Duration
+
+	// SearchQueries are run through SearchURL by Search every
+	// SearchInterval, looking back SearchWindow the first time.
+	SearchURL      string
+	SearchQueries  []string
+	SearchInterval time.Duration
+	SearchWindow   time.Duration
+
+	// PDSURL is where Login creates a session, and HTTPClient makes every
+	// request but those to Jetstream.
+	PDSURL     string
+	HTTPClient *http.Client
+
+	cursor   atomic.Int64
+	searches map[string]*search
+
+	sessionMu            sync.Mutex
+	session              *Session
+	identifier, password string
+
+	dropped atomic.Uint64
 }
 
-func NewClient(logger *slog.Logger, gsheetsClient *gsheets.Client) *Client {
+func NewClient(logger *slog.Logger, sink spreadsheet.Sink) *Client {
 	return &Client{
-		PollInterval:       pollInterval,
-		FeedsConfigFile:    feedsConfigFile,
-		GoogleSheetsClient: gsheetsClient,
-		Logger:             logger,
+		PollInterval:     pollInterval,
+		FeedsConfigFile:  feedsConfigFile,
+		Sink:             sink,
+		Logger:           logger,
+		JetstreamURL:     jetstreamURL,
+		ReconnectBackoff: minReconnectBackoff,
+		SearchURL:        searchURL,
+		SearchInterval:   searchInterval,
+		SearchWindow:     searchWindow,
+		searches:         make(map[string]*search),
+		PDSURL:           pdsURL,
+		HTTPClient:       &http.Client{Timeout: httpTimeout},
 	}
 }
 
-func (c *Client) Run(errs chan error) {
+func (c *Client) Run(ctx context.Context, errs chan error) {
 
 	feeds, err := c.loadFeedsFromConfigFile(c.FeedsConfigFile)
 	if err != nil {
-		errs <- err
+		select {
+		case errs <- err:
+		case <-ctx.Done():
+		}
 	}
 
 	ticker := time.NewTicker(time.Duration(c.PollInterval) * time.Second)
 	defer ticker.Stop()
 
 	for {
-		<-ticker.C
-		c.Logger.Info("Polling bsky now")
-		for _, feedConf := range feeds {
-			err := c.fetchPostsFromFeed(feedConf)
-			if err != nil {
-				errs <- err
+		select {
+		case <-ticker.C:
+			c.Logger.Info("Polling bsky now")
+			for _, feedConf := range feeds {
+				// once cancelled, every feed fails with the context's
+				// error and nobody is reading errs any more
+				err := c.fetchPostsFromFeed(ctx, feedConf)
+				if err != nil && ctx.Err() == nil {
+					select {
+					case errs <- err:
+					case <-ctx.Done():
+					}
+				}
 			}
+		case <-ctx.Done():
+			c.Logger.Info("Context cancelled, shutting down bsky client...", "dropped", c.Dropped())
+			return
 		}
 	}
 }
@@ -111,9 +208,9 @@ func (c *Client) loadFeedsFromConfigFile(feedsConfigfileLoc string) ([]Feed, err
 	return feeds, nil
 }
 
-func (c *Client) fetchPostsFromFeed(feedConfig Feed) error {
+func (c *Client) fetchPostsFromFeed(ctx context.Context, feedConfig Feed) error {
 
-	resp, err := http.Get(feedConfig.MachineUri)
+	resp, err := c.get(ctx, feedConfig.MachineUri)
 	if err != nil {
 		return &bSkyError{Message: "error fetching bsky feed", Err: err}
 	}
@@ -123,6 +220,9 @@ func (c *Client) fetchPostsFromFeed(feedConfig Feed) error {
 	if err != nil {
 		return &bSkyError{Message: "error reading bsky feed response", Err: err}
 	}
+	if resp.StatusCode != http.StatusOK {
+		return &bSkyError{Message: "error fetching bsky feed", Err: fmt.Errorf("%s: %s", resp.Status, body)}
+	}
 
 	var feedResponse FeedResponse
 	if err := json.Unmarshal(body, &feedResponse); err != nil {
@@ -131,40 +231,150 @@ func (c *Client) fetchPostsFromFeed(feedConfig Feed) error {
 
 	for _, feedItem := range feedResponse.Feed {
 		//TODO more specific logic to filter bots / low engagement posts / low follower authors?
+		c.handlePost(ctx, feedItem.Post, post.NYTContentType(strings.ToLower(feedConfig.Label)))
+	}
 
-		url, err := generateBskyUrl(feedItem.Post)
-		if err != nil {
-			log.Printf("error generating bsky url for uri %v", err)
-		}
+	return nil
+}
 
-		c.Logger.Info("Associated URL", url)
-
-		post, err := createPostFromBskyPost(
-			feedItem.Post.CID,
-			url,
-			feedItem.Post.Record.Text,
-			post.NYTContentType(strings.ToLower(feedConfig.Label)),
-		)
-		if err != nil {
-			c.Logger.Error("error creating bsky post for uri", "url", url, "err", err)
-			continue
-		}
+// handlePost writes bskyPost to the sink if it contains NYT content. hint is
+// passed on to detect.
+func (c *Client) handlePost(ctx context.Context, bskyPost BlueskyPost, hint post.NYTContentType) {
+	match, ok := c.detect(bskyPost, hint)
+	if !ok {
+		return
+	}
+
+	url, err := generateBskyUrl(bskyPost)
+	if err != nil {
+		c.Logger.Error("error generating bsky url for uri", "uri", bskyPost.URI, "err", err)
+	}
+
+	c.Logger.Info("Associated URL", "url", url)
+
+	post, err := createPostFromBskyPost(bskyPost, url, match)
+	if err != nil {
+		c.Logger.Error("error creating bsky post for uri", "url", url, "err", err)
+		return
+	}
+
+	if err := c.Sink.Write(ctx, post); err != nil {
+		c.Logger.Error("error writing bsky post to sink", "err", err)
+		return
+	}
+
+	c.Logger.Info("bsky post created", "post", post)
+}
 
-		if err := c.GoogleSheetsClient.AppendRow(post); err != nil {
-			c.Logger.Error("error writing bsky post to google sheet", err)
-			continue
+// Dropped returns the number of feed posts dropped because detection found
+// no NYT content in them.
+func (c *Client) Dropped() uint64 {
+	return c.dropped.Load()
+}
+
+// detect runs a post through the same detection and exclusions as Mastodon
+// statuses. hint is the label of the feed the post came from; feeds are
+// generated by third parties and return posts that are not shares at all,
+// so it is only used to log disagreements with the detected type.
+func (c *Client) detect(bskyPost BlueskyPost, hint post.NYTContentType) (post.Match, bool) {
+	did, _ := bskyPost.Author["did"].(string)
+	candidate := post.Candidate{
+		Text:     post.NormalizeText(bskyPost.Record.Text),
+		URLs:     findURLs(bskyPost),
+		Author:   author(bskyPost),
+		AuthorID: did,
+	}
+
+	match, ok := post.Detect(candidate)
+	if !ok {
+		c.dropped.Add(1)
+		c.Logger.Debug("Dropping bsky post without NYT content", "uri", bskyPost.URI, "feed", hint)
+		return match, false
+	}
+
+	if name, reason, excluded := post.Exclude(candidate, match); excluded {
+		c.Logger.Info("Excluded NYT content", "uri", bskyPost.URI, "type", match.Type, "rule", match.Rule, "exclusion", name, "reason", reason)
+		return post.Match{}, false
+	}
+
+	if match.Type != hint {
+		c.Logger.Debug("bsky post detected as a different type than its feed", "uri", bskyPost.URI, "feed", hint, "type", match.Type, "rule", match.Rule)
+	}
+	return match, true
+}
+
+// findURLs returns the links in a post's facets, its link card and any post
+// it quotes, in that order and without repeats.
+func findURLs(bskyPost BlueskyPost) []string {
+	var (
+		urls []string
+		seen = make(map[string]bool)
+	)
+	add := func(u string) {
+		if u != "" && !seen[u] {
+			seen[u] = true
+			urls = append(urls, u)
 		}
+	}
 
-		c.Logger.Info("bsky post created", "post", post)
+	var (
+		fromRecord func(r *Record)
+		fromEmbed  func(e *Embed)
+		fromQuote  func(q *EmbedRecord)
+	)
+	fromRecord = func(r *Record) {
+		if r == nil {
+			return
+		}
+		for _, f := range r.Facets {
+			for _, feature := range f.Features {
+				if feature.Type == facetLinkType {
+					add(feature.URI)
+				}
+			}
+		}
+		fromEmbed(r.Embed)
+	}
+	fromEmbed = func(e *Embed) {
+		if e == nil {
+			return
+		}
+		if e.External != nil {
+			add(e.External.URI)
+		}
+		fromQuote(e.Record)
+		fromEmbed(e.Media)
+	}
+	fromQuote = func(q *EmbedRecord) {
+		if q == nil {
+			return
+		}
+		fromRecord(q.Value)
+		for i := range q.Embeds {
+			fromEmbed(&q.Embeds[i])
+		}
+		fromQuote(q.Record)
 	}
 
-	return nil
+	fromRecord(&bskyPost.Record)
+	fromEmbed(bskyPost.Embed)
+	return urls
+}
+
+// author returns the handle of a post's author, or their DID for posts read
+// from Jetstream, which does not include handles.
+func author(bskyPost BlueskyPost) string {
+	if handle, ok := bskyPost.Author["handle"].(string); ok && handle != "" {
+		return handle
+	}
+	did, _ := bskyPost.Author["did"].(string)
+	return did
 }
 
 func generateBskyUrl(post BlueskyPost) (string, error) {
 	uri := post.URI
-	handle, ok := post.Author["handle"]
-	if !ok {
+	handle := author(post)
+	if handle == "" {
 		return "", &bSkyError{Message: "error generating bsky urls", Err: fmt.Errorf("author handle invalid")}
 	}
 
@@ -186,22 +396,47 @@ func extractRKey(uri string) (string, error) {
 	return parts[len(parts)-1], nil
 }
 
-func createPostFromBskyPost(CID, URI, content string, postType post.NYTContentType) (post.Post, error) {
+func createPostFromBskyPost(bskyPost BlueskyPost, URI string, match post.Match) (post.Post, error) {
+	content := post.NormalizeText(bskyPost.Record.Text)
+	if content == "" {
+		// posts that are only a link card have no text of their own
+		content = cmp.Or(cardText(bskyPost.Embed), match.URL)
+	}
 	if URI == "" || content == "" {
-		return post.Post{}, &bSkyError{Message: "error creating bsky post", Err: fmt.Errorf("empty content or uri. Content: %s, URI: %s", URI, content)}
+		return post.Post{}, &bSkyError{Message: "error creating bsky post", Err: fmt.Errorf("empty content or uri. Content: %s, URI: %s", content, URI)}
 	}
 
 	post := post.Post{
-		ID:      CID,
-		URI:     URI,
-		Content: content,
-		Type:    postType,
-		Source:  post.BlueSky,
+		ID:         bskyPost.CID,
+		URI:        URI,
+		Content:    content,
+		Type:       match.Type,
+		Rule:       match.Rule,
+		Source:     post.BlueSky,
+		DetectedAt: time.Now().UTC(),
+		Game:       post.ParseGame(match.Type, content),
+		Link:       post.ParseLink(match.URL),
+	}
+	post.Author = author(bskyPost)
+	if len(bskyPost.Record.Langs) > 0 {
+		post.Language = bskyPost.Record.Langs[0]
 	}
 
 	return post, nil
 }
 
+// cardText returns the title of a post's own link card, or its link if it
+// has no title.
+func cardText(embed *Embed) string {
+	if embed == nil {
+		return ""
+	}
+	if embed.External != nil {
+		return cmp.Or(post.NormalizeText(embed.External.Title), embed.External.URI)
+	}
+	return cardText(embed.Media)
+}
+
 // Custom blue sky error type
 type bSkyError struct {
 	Message string
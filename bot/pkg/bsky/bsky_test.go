@@ -0,0 +1,234 @@
+package bsky
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/togdon/reply-bot/bot/pkg/post"
+)
+
+// fakeSink records every post written to it.
+type fakeSink struct {
+	mu    sync.Mutex
+	posts []post.Post
+}
+
+func (f *fakeSink) Write(_ context.Context, p post.Post) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.posts = append(f.posts, p)
+	return nil
+}
+
+func (f *fakeSink) Close() error { return nil }
+
+func feedItem(rkey, text string) FeedItem {
+	return FeedItem{Post: BlueskyPost{
+		URI:    "at://did:plc:abc/app.bsky.feed.post/" + rkey,
+		CID:    "cid-" + rkey,
+		Author: map[string]interface{}{"handle": "player.bsky.social"},
+		Record: Record{Text: text, Langs: []string{"en"}},
+	}}
+}
+
+func TestFetchPostsFromFeed(t *testing.T) {
+	feed := FeedResponse{Feed: []FeedItem{
+		feedItem("wordle", "Wordle 1,236 4/6\n\n⬜🟨⬜⬜⬜\n🟩🟩🟩🟩🟩"),
+		feedItem("connections", "Connections\nPuzzle #523\n🟨🟨🟨🟨\n🟩🟩🟩🟩\n🟦🟦🟦🟦\n🟪🟪🟪🟪"),
+		feedItem("chatter", "Does anyone else play Wordle every morning?"),
+	}}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(feed)
+	}))
+	defer srv.Close()
+
+	sink := &fakeSink{}
+	c := NewClient(slog.New(slog.NewTextHandler(io.Discard, nil)), sink)
+
+	if err := c.fetchPostsFromFeed(context.Background(), Feed{Label: "Wordle", MachineUri: srv.URL}); err != nil {
+		t.Fatalf("fetchPostsFromFeed() error = %v", err)
+	}
+
+	// the feed label is not trusted: the Connections share keeps its own
+	// type and the post that is not a share is dropped
+	want := map[string]post.NYTContentType{
+		"https://bsky.app/profile/player.bsky.social/post/wordle":      post.Wordle,
+		"https://bsky.app/profile/player.bsky.social/post/connections": post.Connections,
+	}
+	if len(sink.posts) != len(want) {
+		t.Fatalf("wrote %d posts, want %d", len(sink.posts), len(want))
+	}
+	for _, p := range sink.posts {
+		if p.Type != want[p.URI] {
+			t.Errorf("post %s has type %q, want %q", p.URI, p.Type, want[p.URI])
+		}
+		if p.Game == nil {
+			t.Errorf("post %s has no game result", p.URI)
+		}
+	}
+	if got := c.Dropped(); got != 1 {
+		t.Errorf("Dropped() = %d, want 1", got)
+	}
+}
+
+func TestRunStopsWhenCancelledDuringPoll(t *testing.T) {
+	polling := make(chan struct{}, 3)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		polling <- struct{}{}
+		<-r.Context().Done()
+	}))
+	defer srv.Close()
+
+	feeds := []Feed{
+		{Label: "Wordle", MachineUri: srv.URL},
+		{Label: "Connections", MachineUri: srv.URL},
+		{Label: "Strands", MachineUri: srv.URL},
+	}
+	path := filepath.Join(t.TempDir(), "bsky-feeds.json")
+	raw, err := json.Marshal(feeds)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, raw, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	c := NewClient(slog.New(slog.NewTextHandler(io.Discard, nil)), &fakeSink{})
+	c.FeedsConfigFile = path
+	c.PollInterval = 1
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errs := make(chan error, 1)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		c.Run(ctx, errs)
+	}()
+
+	<-polling
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(3 * time.Second):
+		t.Fatal("Run() did not return after the context was cancelled")
+	}
+}
+
+// quotePost is a feed item as the AppView returns it: a post with a
+// shortened link in its text, a link card, and a quote of another post
+// alongside an image, each carrying a different link.
+const quotePost = `{
+  "uri": "at://did:plc:abc/app.bsky.feed.post/3kquote",
+  "cid": "bafyquote",
+  "author": {"did": "did:plc:abc", "handle": "cook.bsky.social"},
+  "record": {
+    "$type": "app.bsky.feed.post",
+    "text": "Making this tonight cooking.nytimes.com/recipes/10...",
+    "langs": ["en"],
+    "facets": [
+      {
+        "index": {"byteStart": 20, "byteEnd": 53},
+        "features": [{"$type": "app.bsky.richtext.facet#link", "uri": "https://cooking.nytimes.com/recipes/1025032-chicken-soup"}]
+      },
+      {
+        "index": {"byteStart": 0, "byteEnd": 6},
+        "features": [{"$type": "app.bsky.richtext.facet#mention", "did": "did:plc:xyz"}]
+      }
+    ],
+    "embed": {
+      "$type": "app.bsky.embed.recordWithMedia",
+      "record": {"$type": "app.bsky.embed.record", "record": {"uri": "at://did:plc:xyz/app.bsky.feed.post/3kquoted", "cid": "bafyquoted"}},
+      "media": {"$type": "app.bsky.embed.images", "images": []}
+    }
+  },
+  "embed": {
+    "$type": "app.bsky.embed.recordWithMedia#view",
+    "record": {
+      "$type": "app.bsky.embed.record#view",
+      "record": {
+        "$type": "app.bsky.embed.record#viewRecord",
+        "uri": "at://did:plc:xyz/app.bsky.feed.post/3kquoted",
+        "value": {
+          "$type": "app.bsky.feed.post",
+          "text": "The best pot for soup",
+          "facets": [{"features": [{"$type": "app.bsky.richtext.facet#link", "uri": "https://www.nytimes.com/wirecutter/reviews/best-dutch-oven/"}]}]
+        },
+        "embeds": [
+          {"$type": "app.bsky.embed.external#view", "external": {"uri": "https://www.nytimes.com/2024/11/05/dining/soup.html", "title": "Soup Season"}}
+        ]
+      }
+    },
+    "media": {"$type": "app.bsky.embed.images#view", "images": []}
+  }
+}`
+
+func TestFindURLs(t *testing.T) {
+	var p BlueskyPost
+	if err := json.Unmarshal([]byte(quotePost), &p); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{
+		"https://cooking.nytimes.com/recipes/1025032-chicken-soup",
+		"https://www.nytimes.com/wirecutter/reviews/best-dutch-oven/",
+		"https://www.nytimes.com/2024/11/05/dining/soup.html",
+	}
+	if got := findURLs(p); !reflect.DeepEqual(got, want) {
+		t.Errorf("findURLs() = %v, want %v", got, want)
+	}
+}
+
+func TestDetectLinks(t *testing.T) {
+	c := NewClient(slog.New(slog.NewTextHandler(io.Discard, nil)), &fakeSink{})
+
+	var p BlueskyPost
+	if err := json.Unmarshal([]byte(quotePost), &p); err != nil {
+		t.Fatal(err)
+	}
+	match, ok := c.detect(p, "")
+	if !ok {
+		t.Fatal("detect() found nothing in a post linking to a recipe")
+	}
+	got, err := createPostFromBskyPost(p, "https://bsky.app/profile/cook.bsky.social/post/3kquote", match)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Type != post.Cooking {
+		t.Errorf("Type = %q, want %q", got.Type, post.Cooking)
+	}
+	if got.Rule == "" || got.Rule != match.Rule {
+		t.Errorf("Rule = %q, want the matching rule %q", got.Rule, match.Rule)
+	}
+	if got.Link == nil || got.Link.URL != "https://cooking.nytimes.com/recipes/1025032-chicken-soup" {
+		t.Errorf("Link = %+v, want the recipe from the facet", got.Link)
+	}
+
+	// a link card on its own is enough, and stands in for the missing text
+	card := BlueskyPost{CID: "cid-card", Embed: &Embed{External: &External{URI: "https://www.nytimes.com/2024/11/05/dining/soup.html", Title: "Soup Season"}}}
+	match, ok = c.detect(card, "")
+	if !ok || match.Type != post.Article {
+		t.Errorf("detect() = %v, %v, want type %q", match, ok, post.Article)
+	}
+	got, err = createPostFromBskyPost(card, "https://bsky.app/profile/cook.bsky.social/post/3kcard", match)
+	if err != nil {
+		t.Fatalf("createPostFromBskyPost() error = %v for a post that is only a link card", err)
+	}
+	if got.Content != "Soup Season" {
+		t.Errorf("Content = %q, want the card title", got.Content)
+	}
+
+	card.Embed.External.Title = ""
+	if got, err := createPostFromBskyPost(card, "https://bsky.app/profile/cook.bsky.social/post/3kcard", match); err != nil || got.Content != card.Embed.External.URI {
+		t.Errorf("createPostFromBskyPost() = %q, %v, want the card link as content", got.Content, err)
+	}
+}
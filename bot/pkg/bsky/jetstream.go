@@ -0,0 +1,155 @@
+package bsky
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	jetstreamURL   = "wss://jetstream2.us-east.bsky.network/subscribe"
+	postCollection = "app.bsky.feed.post"
+
+	minReconnectBackoff = time.Second
+	maxReconnectBackoff = 2 * time.Minute
+
+	// jetstreamReadTimeout is how long a connection may go without an event
+	// before it is considered dead; posts arrive many times a second.
+	jetstreamReadTimeout = time.Minute
+
+	// cursorRewind is how far before the last event seen a reconnect
+	// resumes, so events in flight when the connection dropped are not
+	// missed. Posts seen twice are dropped by the dedupe sink.
+	cursorRewind = 5 * time.Second
+)
+
+// jetstreamEvent is a single message from Jetstream. Only commits creating
+// a post are used; identity and account events are skipped.
+type jetstreamEvent struct {
+	DID    string           `json:"did"`
+	TimeUS int64            `json:"time_us"`
+	Kind   string           `json:"kind"`
+	Commit *jetstreamCommit `json:"commit"`
+}
+
+type jetstreamCommit struct {
+	Operation  string `json:"operation"`
+	Collection string `json:"collection"`
+	RKey       string `json:"rkey"`
+	CID        string `json:"cid"`
+	Record     Record `json:"record"`
+}
+
+// Stream reads every new post on the network from the Jetstream at
+// JetstreamURL and writes those containing NYT content to the sink, until
+// ctx is cancelled. Unlike Run it is not limited to what the feed
+// generators index. A dropped connection is retried with backoff, resuming
+// from the last event seen.
+func (c *Client) Stream(ctx context.Context, errs chan error) {
+	backoff := c.ReconnectBackoff
+	for {
+		connected, err := c.stream(ctx)
+		if connected {
+			backoff = c.ReconnectBackoff
+		}
+
+		// the error is not reported once shutting down, as nothing reads it
+		if ctx.Err() == nil {
+			select {
+			case errs <- err:
+			case <-ctx.Done():
+			}
+		}
+
+		c.Logger.Info("Reconnecting to jetstream", "backoff", backoff, "cursor", c.Cursor())
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			c.Logger.Info("Context cancelled, shutting down bsky client...", "dropped", c.Dropped())
+			return
+		}
+		backoff = min(backoff*2, maxReconnectBackoff)
+	}
+}
+
+// Cursor returns the time, in microseconds since the epoch, of the last
+// Jetstream event seen, or 0 if there has not been one.
+func (c *Client) Cursor() int64 {
+	return c.cursor.Load()
+}
+
+// stream reads from a single Jetstream connection until it fails, reporting
+// whether it connected at all.
+func (c *Client) stream(ctx context.Context) (bool, error) {
+	u, err := c.subscribeURL()
+	if err != nil {
+		return false, &bSkyError{Message: "error building jetstream url", Err: err}
+	}
+
+	conn, _, err := websocket.DefaultDialer.DialContext(ctx, u, nil)
+	if err != nil {
+		return false, &bSkyError{Message: "error connecting to jetstream", Err: err}
+	}
+	defer conn.Close()
+
+	// unblocks the read below
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	c.Logger.Info("Streaming bsky posts from jetstream", "url", u)
+
+	for {
+		conn.SetReadDeadline(time.Now().Add(jetstreamReadTimeout))
+		_, msg, err := conn.ReadMessage()
+		if err != nil {
+			return true, &bSkyError{Message: "error reading from jetstream", Err: err}
+		}
+
+		var event jetstreamEvent
+		if err := json.Unmarshal(msg, &event); err != nil {
+			c.Logger.Error("error unmarshaling jetstream event", "err", err)
+			continue
+		}
+		c.handleEvent(ctx, event)
+	}
+}
+
+func (c *Client) handleEvent(ctx context.Context, event jetstreamEvent) {
+	if event.TimeUS > 0 {
+		c.cursor.Store(event.TimeUS)
+	}
+
+	commit := event.Commit
+	if event.Kind != "commit" || commit == nil || commit.Operation != "create" || commit.Collection != postCollection {
+		return
+	}
+
+	c.handlePost(ctx, BlueskyPost{
+		URI:    fmt.Sprintf("at://%s/%s/%s", event.DID, commit.Collection, commit.RKey),
+		CID:    commit.CID,
+		Author: map[string]interface{}{"did": event.DID},
+		Record: commit.Record,
+	}, "")
+}
+
+// subscribeURL returns JetstreamURL asking for posts only, resuming a little
+// before the last event seen.
+func (c *Client) subscribeURL() (string, error) {
+	u, err := url.Parse(c.JetstreamURL)
+	if err != nil {
+		return "", err
+	}
+
+	q := u.Query()
+	q.Set("wantedCollections", postCollection)
+	if cursor := c.Cursor(); cursor > 0 {
+		q.Set("cursor", strconv.FormatInt(cursor-cursorRewind.Microseconds(), 10))
+	}
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
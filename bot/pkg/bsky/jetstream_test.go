@@ -0,0 +1,132 @@
+package bsky
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/togdon/reply-bot/bot/pkg/post"
+)
+
+func commitEvent(timeUS int64, operation, rkey, text string) map[string]interface{} {
+	return map[string]interface{}{
+		"did":     "did:plc:player",
+		"time_us": timeUS,
+		"kind":    "commit",
+		"commit": map[string]interface{}{
+			"rev":        "3l3qo2vuowo2b",
+			"operation":  operation,
+			"collection": postCollection,
+			"rkey":       rkey,
+			"cid":        "cid-" + rkey,
+			"record":     map[string]interface{}{"$type": postCollection, "text": text, "langs": []string{"en"}},
+		},
+	}
+}
+
+func TestStream(t *testing.T) {
+	// the first connection sends a few events and drops, the second checks
+	// the client resumed from where it left off
+	var connections atomic.Int32
+	cursors := make(chan string, 10)
+	upgrader := websocket.Upgrader{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.URL.Query().Get("wantedCollections"); got != postCollection {
+			t.Errorf("wantedCollections = %q, want %q", got, postCollection)
+		}
+		cursors <- r.URL.Query().Get("cursor")
+
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer conn.Close()
+
+		first := connections.Add(1) == 1
+		var events []interface{}
+		if first {
+			events = []interface{}{
+				map[string]interface{}{"did": "did:plc:player", "time_us": 1730000000000000, "kind": "identity"},
+				commitEvent(1730000001000000, "create", "wordle", "Wordle 1,236 4/6\n\n⬜🟨⬜⬜⬜\n🟩🟩🟩🟩🟩"),
+				commitEvent(1730000002000000, "create", "chatter", "Does anyone else play Wordle every morning?"),
+				commitEvent(1730000003000000, "delete", "strands", "Strands #248\n🟡🔵🔵🔵"),
+			}
+		} else {
+			events = []interface{}{
+				commitEvent(1730000004000000, "create", "connections", "Connections\nPuzzle #523\n🟨🟨🟨🟨\n🟩🟩🟩🟩\n🟦🟦🟦🟦\n🟪🟪🟪🟪"),
+			}
+		}
+		for _, e := range events {
+			if err := conn.WriteJSON(e); err != nil {
+				t.Error(err)
+				return
+			}
+		}
+		if !first {
+			// hold the connection open until the client goes away
+			conn.ReadMessage()
+		}
+	}))
+	defer srv.Close()
+
+	sink := &fakeSink{}
+	c := NewClient(slog.New(slog.NewTextHandler(io.Discard, nil)), sink)
+	c.JetstreamURL = "ws" + strings.TrimPrefix(srv.URL, "http") + "/subscribe"
+	c.ReconnectBackoff = time.Millisecond
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	errs := make(chan error, 10)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		c.Stream(ctx, errs)
+	}()
+
+	deadline := time.After(5 * time.Second)
+	for {
+		sink.mu.Lock()
+		n := len(sink.posts)
+		sink.mu.Unlock()
+		if n == 2 {
+			break
+		}
+		select {
+		case <-deadline:
+			t.Fatalf("wrote %d posts, want 2", n)
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+	cancel()
+	<-done
+
+	if got := <-cursors; got != "" {
+		t.Errorf("first connection cursor = %q, want none", got)
+	}
+	want := strconv.FormatInt(1730000003000000-cursorRewind.Microseconds(), 10)
+	if got := <-cursors; got != want {
+		t.Errorf("resumed with cursor %q, want %q", got, want)
+	}
+
+	wantTypes := []post.NYTContentType{post.Wordle, post.Connections}
+	for i, p := range sink.posts {
+		if p.Type != wantTypes[i] {
+			t.Errorf("post %d has type %q, want %q", i, p.Type, wantTypes[i])
+		}
+		if p.Author != "did:plc:player" || !strings.HasPrefix(p.URI, "https://bsky.app/profile/did:plc:player/post/") {
+			t.Errorf("post %d has author %q and URI %q, want them from the DID", i, p.Author, p.URI)
+		}
+	}
+	if got := c.Dropped(); got != 1 {
+		t.Errorf("Dropped() = %d, want 1", got)
+	}
+}
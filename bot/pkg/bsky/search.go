@@ -0,0 +1,138 @@
+package bsky
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+const (
+	searchURL      = "https://public.api.bsky.app/xrpc/app.bsky.feed.searchPosts"
+	searchInterval = time.Minute
+	searchWindow   = time.Hour
+	searchLimit    = 100
+
+	// searchPages caps the pages read for one query per tick; a query with
+	// more results picks up from its cursor on the next tick.
+	searchPages = 5
+)
+
+type SearchResponse struct {
+	Cursor string        `json:"cursor"`
+	Posts  []BlueskyPost `json:"posts"`
+}
+
+// search is the state kept for a single query between ticks.
+type search struct {
+	// since is where the last complete pass over the results stopped, and
+	// newest the most recent post seen in the current one, which becomes
+	// since once it completes.
+	since  time.Time
+	newest time.Time
+	// cursor is where the current pass left off, if it is not complete.
+	cursor string
+}
+
+// Search runs every query in SearchQueries through searchPosts each
+// SearchInterval and writes the posts containing NYT content to the sink,
+// until ctx is cancelled. Each query only asks for posts since the newest
+// one it has already seen, looking back SearchWindow on the first tick.
+func (c *Client) Search(ctx context.Context, errs chan error) {
+	ticker := time.NewTicker(c.SearchInterval)
+	defer ticker.Stop()
+
+	for {
+		c.Logger.Info("Searching bsky now", "queries", len(c.SearchQueries))
+		for _, q := range c.SearchQueries {
+			if err := c.searchPosts(ctx, q); err != nil && ctx.Err() == nil {
+				select {
+				case errs <- err:
+				case <-ctx.Done():
+				}
+			}
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			c.Logger.Info("Context cancelled, shutting down bsky search...", "dropped", c.Dropped())
+			return
+		}
+	}
+}
+
+// searchPosts reads up to searchPages pages of results for q.
+func (c *Client) searchPosts(ctx context.Context, q string) error {
+	s, ok := c.searches[q]
+	if !ok {
+		s = &search{since: time.Now().UTC().Add(-c.SearchWindow)}
+		c.searches[q] = s
+	}
+
+	for range searchPages {
+		resp, err := c.fetchSearchPage(ctx, q, s)
+		if err != nil {
+			return err
+		}
+
+		for _, p := range resp.Posts {
+			if p.IndexedAt.After(s.newest) {
+				s.newest = p.IndexedAt
+			}
+			c.handlePost(ctx, p, "")
+		}
+
+		if resp.Cursor == "" || len(resp.Posts) == 0 {
+			if s.newest.After(s.since) {
+				s.since = s.newest
+			}
+			s.cursor = ""
+			return nil
+		}
+		s.cursor = resp.Cursor
+	}
+
+	c.Logger.Info("bsky search has more results, continuing next time", "q", q)
+	return nil
+}
+
+func (c *Client) fetchSearchPage(ctx context.Context, q string, s *search) (SearchResponse, error) {
+	u, err := url.Parse(c.SearchURL)
+	if err != nil {
+		return SearchResponse{}, &bSkyError{Message: "error building bsky search url", Err: err}
+	}
+	params := u.Query()
+	params.Set("q", q)
+	params.Set("sort", "latest")
+	params.Set("limit", strconv.Itoa(searchLimit))
+	params.Set("since", s.since.Format(time.RFC3339Nano))
+	if s.cursor != "" {
+		params.Set("cursor", s.cursor)
+	}
+	u.RawQuery = params.Encode()
+
+	resp, err := c.get(ctx, u.String())
+	if err != nil {
+		return SearchResponse{}, &bSkyError{Message: "error searching bsky", Err: err}
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return SearchResponse{}, &bSkyError{Message: "error reading bsky search response", Err: err}
+	}
+	if resp.StatusCode != http.StatusOK {
+		return SearchResponse{}, &bSkyError{Message: "error searching bsky", Err: fmt.Errorf("q %q: %s: %s", q, resp.Status, body)}
+	}
+
+	var searchResponse SearchResponse
+	if err := json.Unmarshal(body, &searchResponse); err != nil {
+		return SearchResponse{}, &bSkyError{Message: "error unmarshaling bsky search response", Err: err}
+	}
+	return searchResponse, nil
+}
@@ -0,0 +1,114 @@
+package bsky
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/togdon/reply-bot/bot/pkg/post"
+)
+
+func searchHit(rkey, text string, indexedAt time.Time) BlueskyPost {
+	p := feedItem(rkey, text).Post
+	p.IndexedAt = indexedAt
+	return p
+}
+
+func TestSearchPosts(t *testing.T) {
+	newest := time.Now().UTC().Truncate(time.Second)
+
+	var (
+		mu       sync.Mutex
+		requests []map[string]string
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		mu.Lock()
+		requests = append(requests, map[string]string{"q": q.Get("q"), "since": q.Get("since"), "cursor": q.Get("cursor")})
+		mu.Unlock()
+
+		var resp SearchResponse
+		switch {
+		case q.Get("q") == "endless":
+			// always has another page
+			n, _ := strconv.Atoi(q.Get("cursor"))
+			resp.Cursor = strconv.Itoa(n + 1)
+			resp.Posts = []BlueskyPost{searchHit("endless"+resp.Cursor, "Does anyone else play Wordle?", newest)}
+		case q.Get("cursor") == "" && q.Get("since") != newest.Format(time.RFC3339Nano):
+			resp.Cursor = "page2"
+			resp.Posts = []BlueskyPost{
+				searchHit("wordle", "Wordle 1,236 4/6\n\n⬜🟨⬜⬜⬜\n🟩🟩🟩🟩🟩", newest),
+				searchHit("chatter", "Does anyone else play Wordle every morning?", newest.Add(-time.Minute)),
+			}
+		case q.Get("cursor") == "page2":
+			resp.Cursor = "page3"
+			resp.Posts = []BlueskyPost{
+				searchHit("older", "Wordle 1,235 3/6\n\n🟩🟩🟩🟩🟩", newest.Add(-time.Hour)),
+			}
+		}
+		json.NewEncoder(w).Encode(resp)
+	}))
+	defer srv.Close()
+
+	sink := &fakeSink{}
+	c := NewClient(slog.New(slog.NewTextHandler(io.Discard, nil)), sink)
+	c.SearchURL = srv.URL
+
+	// a complete pass reads every page, then the next one only asks for
+	// posts since the newest it saw
+	for range 2 {
+		if err := c.searchPosts(context.Background(), "Wordle"); err != nil {
+			t.Fatalf("searchPosts() error = %v", err)
+		}
+	}
+	if len(requests) != 4 {
+		t.Fatalf("made %d requests, want 4: %v", len(requests), requests)
+	}
+	first, err := time.Parse(time.RFC3339Nano, requests[0]["since"])
+	if err != nil || time.Since(first) < c.SearchWindow || time.Since(first) > c.SearchWindow+time.Minute {
+		t.Errorf("first request since = %q, want %v ago", requests[0]["since"], c.SearchWindow)
+	}
+	for i, cursor := range []string{"", "page2", "page3"} {
+		if requests[i]["cursor"] != cursor || requests[i]["since"] != requests[0]["since"] {
+			t.Errorf("request %d = %v, want cursor %q and the same since", i, requests[i], cursor)
+		}
+	}
+	if got := requests[3]; got["since"] != newest.Format(time.RFC3339Nano) || got["cursor"] != "" {
+		t.Errorf("second pass request = %v, want since %v and no cursor", got, newest)
+	}
+
+	var rkeys []string
+	for _, p := range sink.posts {
+		if p.Type != post.Wordle {
+			t.Errorf("post %s has type %q, want %q", p.URI, p.Type, post.Wordle)
+		}
+		rkeys = append(rkeys, p.URI)
+	}
+	if len(rkeys) != 2 || c.Dropped() != 1 {
+		t.Errorf("wrote %v and dropped %d, want the two shares written and the chatter dropped", rkeys, c.Dropped())
+	}
+
+	// a query with more pages than one tick reads picks up from its cursor
+	requests = nil
+	for range 2 {
+		if err := c.searchPosts(context.Background(), "endless"); err != nil {
+			t.Fatalf("searchPosts() error = %v", err)
+		}
+	}
+	if len(requests) != 2*searchPages {
+		t.Fatalf("made %d requests, want %d", len(requests), 2*searchPages)
+	}
+	if got, want := requests[searchPages]["cursor"], strconv.Itoa(searchPages); got != want {
+		t.Errorf("second tick started from cursor %q, want %q", got, want)
+	}
+	if got := requests[searchPages]["since"]; got != requests[0]["since"] {
+		t.Errorf("second tick since = %q, want it unchanged until a pass completes", got)
+	}
+}
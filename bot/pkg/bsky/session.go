@@ -0,0 +1,190 @@
+package bsky
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	pdsURL        = "https://bsky.social"
+	publicAPIHost = "public.api.bsky.app"
+	httpTimeout   = 30 * time.Second
+
+	createSessionPath  = "/xrpc/com.atproto.server.createSession"
+	refreshSessionPath = "/xrpc/com.atproto.server.refreshSession"
+)
+
+// Session is an app-password session on a PDS. AccessJwt authorises
+// requests until it expires, after which RefreshJwt gets a new pair.
+type Session struct {
+	DID        string `json:"did"`
+	Handle     string `json:"handle"`
+	AccessJwt  string `json:"accessJwt"`
+	RefreshJwt string `json:"refreshJwt"`
+}
+
+// xrpcError is the body of a failed XRPC request.
+type xrpcError struct {
+	Error   string `json:"error"`
+	Message string `json:"message"`
+}
+
+// Login creates a session on PDSURL with an app password. From then on
+// requests are authorised with it and those meant for the public AppView
+// go through the PDS instead, which is not subject to the public rate
+// limits and is required by some endpoints such as searchPosts. The
+// session is refreshed when it expires, and created again with the same
+// credentials if the refresh token has expired too.
+func (c *Client) Login(ctx context.Context, identifier, password string) error {
+	c.sessionMu.Lock()
+	defer c.sessionMu.Unlock()
+
+	c.identifier, c.password = identifier, password
+	if err := c.createSession(ctx); err != nil {
+		return err
+	}
+	c.Logger.Info("Logged in to bsky", "handle", c.session.Handle, "pds", c.PDSURL)
+	return nil
+}
+
+// get fetches rawURL, authorised with the current session if there is
+// one, refreshing the session and retrying once if it has expired.
+func (c *Client) get(ctx context.Context, rawURL string) (*http.Response, error) {
+	c.sessionMu.Lock()
+	session := c.session
+	c.sessionMu.Unlock()
+
+	resp, err := c.doGet(ctx, rawURL, session)
+	if err != nil || session == nil || !expired(resp) {
+		return resp, err
+	}
+
+	if err := c.refreshSession(ctx, session); err != nil {
+		return nil, err
+	}
+	c.sessionMu.Lock()
+	session = c.session
+	c.sessionMu.Unlock()
+	return c.doGet(ctx, rawURL, session)
+}
+
+func (c *Client) doGet(ctx context.Context, rawURL string, session *Session) (*http.Response, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	if session != nil && u.Host == publicAPIHost {
+		pds, err := url.Parse(c.PDSURL)
+		if err != nil {
+			return nil, err
+		}
+		u.Scheme, u.Host = pds.Scheme, pds.Host
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	if session != nil {
+		req.Header.Set("Authorization", "Bearer "+session.AccessJwt)
+	}
+	return c.HTTPClient.Do(req)
+}
+
+// expired reports whether resp failed because the access token has
+// expired, closing its body if so.
+func expired(resp *http.Response) bool {
+	if resp.StatusCode != http.StatusBadRequest && resp.StatusCode != http.StatusUnauthorized {
+		return false
+	}
+
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	var xerr xrpcError
+	if json.Unmarshal(body, &xerr) != nil || xerr.Error != "ExpiredToken" {
+		return false
+	}
+	return true
+}
+
+// refreshSession replaces stale with a refreshed session, unless another
+// request already has.
+func (c *Client) refreshSession(ctx context.Context, stale *Session) error {
+	c.sessionMu.Lock()
+	defer c.sessionMu.Unlock()
+
+	if c.session != stale {
+		return nil
+	}
+
+	session, err := c.sessionRequest(ctx, refreshSessionPath, stale.RefreshJwt, nil)
+	if err == nil {
+		c.session = session
+		c.Logger.Debug("Refreshed bsky session", "handle", session.Handle)
+		return nil
+	}
+
+	c.Logger.Info("Unable to refresh bsky session, logging in again", "err", err)
+	return c.createSession(ctx)
+}
+
+// createSession logs in with the stored credentials. sessionMu must be held.
+func (c *Client) createSession(ctx context.Context) error {
+	body, err := json.Marshal(map[string]string{"identifier": c.identifier, "password": c.password})
+	if err != nil {
+		return &bSkyError{Message: "error creating bsky session", Err: err}
+	}
+
+	session, err := c.sessionRequest(ctx, createSessionPath, "", body)
+	if err != nil {
+		return err
+	}
+	c.session = session
+	return nil
+}
+
+// sessionRequest posts body to one of the session endpoints on the PDS,
+// authorised with token if it is set.
+func (c *Client) sessionRequest(ctx context.Context, path, token string, body []byte) (*Session, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(c.PDSURL, "/")+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, &bSkyError{Message: "error creating bsky session request", Err: err}
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return nil, &bSkyError{Message: "error requesting bsky session", Err: err}
+	}
+	defer resp.Body.Close()
+
+	raw, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, &bSkyError{Message: "error reading bsky session response", Err: err}
+	}
+	if resp.StatusCode != http.StatusOK {
+		var xerr xrpcError
+		json.Unmarshal(raw, &xerr)
+		return nil, &bSkyError{Message: "error requesting bsky session", Err: fmt.Errorf("%s: %s %s", resp.Status, xerr.Error, xerr.Message)}
+	}
+
+	var session Session
+	if err := json.Unmarshal(raw, &session); err != nil {
+		return nil, &bSkyError{Message: "error unmarshaling bsky session", Err: err}
+	}
+	return &session, nil
+}
@@ -0,0 +1,111 @@
+package bsky
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+// fakePDS issues sessions and serves a feed to whoever holds the current
+// access token, answering stale ones the way a PDS does.
+type fakePDS struct {
+	mu         sync.Mutex
+	access     string
+	refresh    string
+	logins     int
+	refreshes  int
+	canRefresh bool
+}
+
+func (p *fakePDS) expire(canRefresh bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.access = "expired"
+	p.canRefresh = canRefresh
+}
+
+func (p *fakePDS) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	expired := func() {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(xrpcError{Error: "ExpiredToken", Message: "Token has expired"})
+	}
+	issue := func(access, refresh string) {
+		p.access, p.refresh = access, refresh
+		json.NewEncoder(w).Encode(Session{DID: "did:plc:bot", Handle: "bot.bsky.social", AccessJwt: access, RefreshJwt: refresh})
+	}
+
+	switch r.URL.Path {
+	case createSessionPath:
+		var creds map[string]string
+		json.NewDecoder(r.Body).Decode(&creds)
+		if creds["identifier"] != "bot.bsky.social" || creds["password"] != "app-password" {
+			w.WriteHeader(http.StatusUnauthorized)
+			json.NewEncoder(w).Encode(xrpcError{Error: "AuthenticationRequired", Message: "Invalid identifier or password"})
+			return
+		}
+		p.logins++
+		issue("access-login", "refresh-login")
+	case refreshSessionPath:
+		if !p.canRefresh || r.Header.Get("Authorization") != "Bearer "+p.refresh {
+			expired()
+			return
+		}
+		p.refreshes++
+		issue("access-refreshed", "refresh-refreshed")
+	case "/xrpc/app.bsky.feed.getFeed":
+		if r.Header.Get("Authorization") != "Bearer "+p.access {
+			expired()
+			return
+		}
+		json.NewEncoder(w).Encode(FeedResponse{})
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func TestSession(t *testing.T) {
+	pds := &fakePDS{}
+	srv := httptest.NewServer(pds)
+	defer srv.Close()
+
+	c := NewClient(slog.New(slog.NewTextHandler(io.Discard, nil)), &fakeSink{})
+	c.PDSURL = srv.URL
+	ctx := context.Background()
+
+	if err := c.Login(ctx, "bot.bsky.social", "wrong"); err == nil {
+		t.Fatal("Login() with the wrong password should fail")
+	}
+	if err := c.Login(ctx, "bot.bsky.social", "app-password"); err != nil {
+		t.Fatalf("Login() error = %v", err)
+	}
+
+	// the public API is reached through the PDS once logged in
+	feed := Feed{Label: "wordle", MachineUri: "https://public.api.bsky.app/xrpc/app.bsky.feed.getFeed?feed=at://did:plc:abc/app.bsky.feed.generator/wordle"}
+	if err := c.fetchPostsFromFeed(ctx, feed); err != nil {
+		t.Fatalf("fetchPostsFromFeed() error = %v", err)
+	}
+
+	pds.expire(true)
+	if err := c.fetchPostsFromFeed(ctx, feed); err != nil {
+		t.Fatalf("fetchPostsFromFeed() with an expired access token error = %v", err)
+	}
+	if pds.refreshes != 1 || pds.logins != 1 {
+		t.Errorf("refreshed %d times and logged in %d times, want the session refreshed", pds.refreshes, pds.logins)
+	}
+
+	pds.expire(false)
+	if err := c.fetchPostsFromFeed(ctx, feed); err != nil {
+		t.Fatalf("fetchPostsFromFeed() with an expired refresh token error = %v", err)
+	}
+	if pds.refreshes != 1 || pds.logins != 2 {
+		t.Errorf("refreshed %d times and logged in %d times, want a new login", pds.refreshes, pds.logins)
+	}
+}
@@ -0,0 +1,123 @@
+package dedupe
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+
+	"github.com/togdon/reply-bot/bot/pkg/post"
+	"github.com/togdon/reply-bot/bot/pkg/spreadsheet"
+)
+
+var _ spreadsheet.Sink = (*Sink)(nil)
+
+// Store remembers which posts have been written beyond the lifetime of the
+// process, e.g. so a restart does not re-append the posts still in a feed.
+type Store interface {
+	Seen(ctx context.Context, key string) (bool, error)
+	Add(ctx context.Context, key string) error
+}
+
+// Key identifies a post across every source.
+func Key(p post.Post) string {
+	return string(p.Source) + ":" + p.ID
+}
+
+// Sink passes each post on to the next sink only the first time it is
+// seen, so repeated polls of a bsky feed or the same status arriving on
+// several Mastodon streams end up in the sheet once.
+type Sink struct {
+	next   spreadsheet.Sink
+	store  Store
+	logger *slog.Logger
+
+	mu       sync.Mutex
+	recent   *lru
+	inflight map[string]struct{}
+
+	duplicates atomic.Uint64
+}
+
+// New returns a Sink remembering the last size keys in memory. store is
+// optional and consulted for keys that have fallen out of (or were never
+// in) the in-memory cache.
+func New(logger *slog.Logger, next spreadsheet.Sink, size int, store Store) *Sink {
+	return &Sink{
+		next:     next,
+		store:    store,
+		logger:   logger,
+		recent:   newLRU(size),
+		inflight: make(map[string]struct{}),
+	}
+}
+
+// Write implements spreadsheet.Sink. Duplicates are dropped without error.
+func (s *Sink) Write(ctx context.Context, p post.Post) error {
+	key := Key(p)
+
+	s.mu.Lock()
+	_, busy := s.inflight[key]
+	if busy || s.recent.contains(key) {
+		s.mu.Unlock()
+		s.duplicate(p)
+		return nil
+	}
+	s.inflight[key] = struct{}{}
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		delete(s.inflight, key)
+		s.mu.Unlock()
+	}()
+
+	if s.store != nil {
+		seen, err := s.store.Seen(ctx, key)
+		if err != nil {
+			// better to risk a duplicate row than to lose the post
+			s.logger.Error("unable to check dedupe store", "key", key, "err", err)
+		} else if seen {
+			s.remember(key)
+			s.duplicate(p)
+			return nil
+		}
+	}
+
+	// once any sink behind a FanOut has the post, writing it again would
+	// duplicate it there, so it is remembered even if the others failed
+	err := s.next.Write(ctx, p)
+	if !spreadsheet.Accepted(err) {
+		return err
+	}
+
+	s.remember(key)
+	if s.store != nil {
+		if err := s.store.Add(ctx, key); err != nil {
+			s.logger.Error("unable to record post in dedupe store", "key", key, "err", err)
+		}
+	}
+	return err
+}
+
+// Close implements spreadsheet.Sink by closing the next sink. The store is
+// owned by the caller.
+func (s *Sink) Close() error {
+	return s.next.Close()
+}
+
+// Duplicates returns the number of posts dropped as duplicates.
+func (s *Sink) Duplicates() uint64 {
+	return s.duplicates.Load()
+}
+
+func (s *Sink) remember(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.recent.add(key)
+}
+
+func (s *Sink) duplicate(p post.Post) {
+	s.duplicates.Add(1)
+	s.logger.Debug("Dropping duplicate post", "source", p.Source, "id", p.ID)
+}
@@ -0,0 +1,150 @@
+package dedupe
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/togdon/reply-bot/bot/pkg/post"
+	"github.com/togdon/reply-bot/bot/pkg/spreadsheet"
+)
+
+type fakeSink struct {
+	mu    sync.Mutex
+	err   error
+	posts []post.Post
+}
+
+func (f *fakeSink) Write(_ context.Context, p post.Post) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.err != nil {
+		return f.err
+	}
+	f.posts = append(f.posts, p)
+	return nil
+}
+
+func (f *fakeSink) Close() error { return nil }
+
+type memStore map[string]bool
+
+func (m memStore) Seen(_ context.Context, key string) (bool, error) { return m[key], nil }
+
+func (m memStore) Add(_ context.Context, key string) error {
+	m[key] = true
+	return nil
+}
+
+var logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+func TestWrite(t *testing.T) {
+	var (
+		wordle  = post.Post{ID: "https://mastodon.social/@a/1", Source: post.Mastodon, Type: post.Wordle}
+		strands = post.Post{ID: "https://mastodon.social/@a/2", Source: post.Mastodon, Type: post.Strands}
+		// the same ID from another source is a different post
+		bsky = post.Post{ID: "https://mastodon.social/@a/1", Source: post.BlueSky, Type: post.Wordle}
+	)
+
+	tests := []struct {
+		name   string
+		size   int
+		store  memStore
+		writes []post.Post
+		want   []post.Post
+	}{
+		{
+			name:   "repeats are dropped",
+			size:   10,
+			writes: []post.Post{wordle, strands, wordle, bsky, strands, bsky},
+			want:   []post.Post{wordle, strands, bsky},
+		},
+		{
+			name:   "evicted keys are written again without a store",
+			size:   1,
+			writes: []post.Post{wordle, strands, wordle},
+			want:   []post.Post{wordle, strands, wordle},
+		},
+		{
+			name:   "store catches evicted keys",
+			size:   1,
+			store:  memStore{},
+			writes: []post.Post{wordle, strands, wordle},
+			want:   []post.Post{wordle, strands},
+		},
+		{
+			name:   "store catches posts from before a restart",
+			size:   10,
+			store:  memStore{Key(wordle): true},
+			writes: []post.Post{wordle, strands},
+			want:   []post.Post{strands},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			next := &fakeSink{}
+			var store Store
+			if tt.store != nil {
+				store = tt.store
+			}
+			s := New(logger, next, tt.size, store)
+
+			for _, p := range tt.writes {
+				if err := s.Write(context.Background(), p); err != nil {
+					t.Fatalf("Write() error = %v", err)
+				}
+			}
+
+			if !reflect.DeepEqual(next.posts, tt.want) {
+				t.Errorf("next sink received %v, want %v", next.posts, tt.want)
+			}
+			if got, want := s.Duplicates(), uint64(len(tt.writes)-len(tt.want)); got != want {
+				t.Errorf("Duplicates() = %d, want %d", got, want)
+			}
+		})
+	}
+}
+
+func TestWriteFailureIsNotRemembered(t *testing.T) {
+	next := &fakeSink{err: errors.New("sheet unavailable")}
+	s := New(logger, next, 10, nil)
+	p := post.Post{ID: "1", Source: post.BlueSky}
+
+	if err := s.Write(context.Background(), p); err == nil {
+		t.Fatal("Write() error = nil, want the next sink's error")
+	}
+
+	next.err = nil
+	if err := s.Write(context.Background(), p); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	if !reflect.DeepEqual(next.posts, []post.Post{p}) {
+		t.Errorf("next sink received %v, want %v", next.posts, []post.Post{p})
+	}
+}
+
+func TestWritePartialFailureIsRemembered(t *testing.T) {
+	next := &fakeSink{err: &spreadsheet.PartialError{Results: spreadsheet.Results{
+		{Sink: "sqlite"},
+		{Sink: "gsheets", Err: errors.New("sheet unavailable")},
+	}}}
+	s := New(logger, next, 10, memStore{})
+	p := post.Post{ID: "1", Source: post.BlueSky}
+
+	if err := s.Write(context.Background(), p); err == nil {
+		t.Fatal("Write() error = nil, want the failing sink's error")
+	}
+
+	next.err = nil
+	if err := s.Write(context.Background(), p); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	if len(next.posts) != 0 {
+		t.Errorf("next sink received %v, want the post dropped as a duplicate", next.posts)
+	}
+}
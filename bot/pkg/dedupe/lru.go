@@ -0,0 +1,44 @@
+package dedupe
+
+import "container/list"
+
+// lru is a fixed-size set of keys that forgets the least recently used key
+// once it is full. It is not safe for concurrent use.
+type lru struct {
+	size  int
+	order *list.List
+	keys  map[string]*list.Element
+}
+
+func newLRU(size int) *lru {
+	return &lru{
+		size:  size,
+		order: list.New(),
+		keys:  make(map[string]*list.Element, size),
+	}
+}
+
+// contains reports whether key is in the set, marking it as recently used.
+func (l *lru) contains(key string) bool {
+	e, ok := l.keys[key]
+	if ok {
+		l.order.MoveToFront(e)
+	}
+	return ok
+}
+
+// add inserts key, evicting the least recently used key if the set is full.
+func (l *lru) add(key string) {
+	if l.contains(key) {
+		return
+	}
+	if l.size <= 0 {
+		return
+	}
+	if l.order.Len() >= l.size {
+		oldest := l.order.Back()
+		l.order.Remove(oldest)
+		delete(l.keys, oldest.Value.(string))
+	}
+	l.keys[key] = l.order.PushFront(key)
+}
@@ -1,17 +1,37 @@
 package environment
 
 import (
+	"fmt"
 	"strings"
+	"time"
 
 	"github.com/caarlos0/env/v11"
 
 	"log/slog"
 )
 
+const (
+	SinkGSheets = "gsheets"
+	SinkSQLite  = "sqlite"
+	SinkJSONL   = "jsonl"
+	SinkCSV     = "csv"
+
+	BskyFeeds     = "feeds"
+	BskyJetstream = "jetstream"
+)
+
 type Config struct {
-	LogLevel string `env:"LOG_LEVEL" envDefault:"info"`
-	Mastodon Mastodon
-	Google   Google
+	LogLevel    string        `env:"LOG_LEVEL" envDefault:"info"`
+	Sinks       []string      `env:"SINKS" envDefault:"gsheets" envSeparator:","`
+	SinkTimeout time.Duration `env:"SINK_TIMEOUT" envDefault:"30s"`
+	Mastodon    Mastodon
+	Bluesky     Bluesky
+	Google      Google
+	SQLite      SQLite
+	File        File
+	Outbox      Outbox
+	Dedupe      Dedupe
+	Rules       Rules
 }
 
 type Mastodon struct {
@@ -21,9 +41,81 @@ type Mastodon struct {
 	AccessToken    string `env:"MASTODON_ACCESS_TOKEN,notEmpty"`
 }
 
+// Bluesky selects how posts are read from Bluesky: by polling the feeds in
+// bsky-feeds.json ("feeds"), or every post on the network from the
+// Jetstream at JetstreamURL ("jetstream"). Either way, any SearchQueries
+// (e.g. "Wordle,cooking.nytimes.com") are also searched every
+// SearchInterval, looking back SearchWindow at startup.
+//
+// With Identifier and AppPassword set, the client logs in to PDSURL and
+// makes its requests through the PDS rather than the rate limited public
+// API; search requires it. Every request is given up after HTTPTimeout.
+type Bluesky struct {
+	Identifier     string        `env:"BSKY_IDENTIFIER"`
+	AppPassword    string        `env:"BSKY_APP_PASSWORD"`
+	PDSURL         string        `env:"BSKY_PDS_URL" envDefault:"https://bsky.social"`
+	HTTPTimeout    time.Duration `env:"BSKY_HTTP_TIMEOUT" envDefault:"30s"`
+	Mode           string        `env:"BSKY_MODE" envDefault:"feeds"`
+	JetstreamURL   string        `env:"BSKY_JETSTREAM_URL" envDefault:"wss://jetstream2.us-east.bsky.network/subscribe"`
+	SearchQueries  []string      `env:"BSKY_SEARCH_QUERIES" envSeparator:","`
+	SearchInterval time.Duration `env:"BSKY_SEARCH_INTERVAL" envDefault:"1m"`
+	SearchWindow   time.Duration `env:"BSKY_SEARCH_WINDOW" envDefault:"1h"`
+}
+
+// Google configures the gsheets sink. Up to BatchSize rows are coalesced
+// into a single append, waiting at most FlushInterval for a batch to fill.
+// With SheetPerType set each content type is written to its own tab.
+// Columns lays out each row (see gsheets.ParseColumns; empty keeps the
+// default layout) and is checked against the sheet's header row at startup,
+// so a custom layout cannot write into the wrong columns. With the default
+// layout the check only runs with ValidateHeader set, since existing sheets
+// may have no header row.
 type Google struct {
-	Credentials string `env:"GOOGLE_APPLICATION_CREDENTIALS,required"`
-	SheetName   string `env:"GOOGLE_SHEET_NAME" envDefault:"test"`
+	Credentials    string        `env:"GOOGLE_APPLICATION_CREDENTIALS"`
+	SheetID        string        `env:"GOOGLE_SHEET_ID" envDefault:"1wD8zsIcn9vUPmL749MFAreXx8cfaYeqRfFoGuSnJ2Lk"`
+	SheetName      string        `env:"GOOGLE_SHEET_NAME" envDefault:"test"`
+	SheetPerType   bool          `env:"GOOGLE_SHEET_PER_TYPE"`
+	Columns        string        `env:"GOOGLE_COLUMNS"`
+	ValidateHeader bool          `env:"GOOGLE_VALIDATE_HEADER"`
+	BatchSize      int           `env:"GOOGLE_BATCH_SIZE" envDefault:"20"`
+	FlushInterval  time.Duration `env:"GOOGLE_FLUSH_INTERVAL" envDefault:"2s"`
+}
+
+type SQLite struct {
+	Path string `env:"SQLITE_PATH" envDefault:"reply-bot.db"`
+}
+
+// File configures the jsonl and csv sinks. MaxBytes of 0 disables size
+// based rotation.
+type File struct {
+	Dir         string `env:"FILE_SINK_DIR" envDefault:"posts"`
+	Prefix      string `env:"FILE_SINK_PREFIX" envDefault:"posts"`
+	MaxBytes    int64  `env:"FILE_SINK_MAX_BYTES" envDefault:"0"`
+	RotateDaily bool   `env:"FILE_SINK_ROTATE_DAILY" envDefault:"true"`
+}
+
+// Outbox configures the durable queue kept in front of every sink. An empty
+// Dir disables it and writes go straight to the sinks, so each post waits
+// for the slowest sink, for up to SinkTimeout.
+type Outbox struct {
+	Dir        string        `env:"OUTBOX_DIR" envDefault:"outbox"`
+	MinBackoff time.Duration `env:"OUTBOX_MIN_BACKOFF" envDefault:"1s"`
+	MaxBackoff time.Duration `env:"OUTBOX_MAX_BACKOFF" envDefault:"5m"`
+}
+
+// Dedupe configures how many recently written posts are remembered in
+// memory, and optionally a persistent store ("sqlite", at SQLITE_PATH) so
+// duplicates are also caught across restarts.
+type Dedupe struct {
+	CacheSize int    `env:"DEDUPE_CACHE_SIZE" envDefault:"10000"`
+	Store     string `env:"DEDUPE_STORE"`
+}
+
+// Rules configures an optional detection rules file (see rules.Rule), which
+// is reloaded when it changes, checked every PollInterval, or on SIGHUP.
+type Rules struct {
+	File         string        `env:"RULES_FILE"`
+	PollInterval time.Duration `env:"RULES_POLL_INTERVAL" envDefault:"30s"`
 }
 
 func New() (*Config, error) {
@@ -32,11 +124,89 @@ func New() (*Config, error) {
 	if err != nil {
 		return nil, err
 	}
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
 	return &cfg, nil
 
 }
 
-func (c *Config) GetLogLevel() slog.Level{
+// validate checks the settings that depend on which sinks are selected.
+func (c *Config) validate() error {
+	if len(c.Sinks) == 0 {
+		return fmt.Errorf(`environment variable "SINKS" should not be empty`)
+	}
+	if c.Rules.File != "" && c.Rules.PollInterval <= 0 {
+		return fmt.Errorf(`environment variable "RULES_POLL_INTERVAL" should be positive`)
+	}
+
+	c.Bluesky.Mode = strings.ToLower(strings.TrimSpace(c.Bluesky.Mode))
+	switch c.Bluesky.Mode {
+	case BskyFeeds:
+	case BskyJetstream:
+		if c.Bluesky.JetstreamURL == "" {
+			return fmt.Errorf(`environment variable "BSKY_JETSTREAM_URL" should not be empty`)
+		}
+	default:
+		return fmt.Errorf("unknown BSKY_MODE %q, expected %q or %q", c.Bluesky.Mode, BskyFeeds, BskyJetstream)
+	}
+	if (c.Bluesky.Identifier == "") != (c.Bluesky.AppPassword == "") {
+		return fmt.Errorf(`environment variables "BSKY_IDENTIFIER" and "BSKY_APP_PASSWORD" should be set together`)
+	}
+	if c.Bluesky.Identifier != "" && c.Bluesky.PDSURL == "" {
+		return fmt.Errorf(`environment variable "BSKY_PDS_URL" should not be empty`)
+	}
+	if c.Bluesky.HTTPTimeout <= 0 {
+		return fmt.Errorf(`environment variable "BSKY_HTTP_TIMEOUT" should be positive`)
+	}
+	if len(c.Bluesky.SearchQueries) > 0 && c.Bluesky.Identifier == "" {
+		return fmt.Errorf(`environment variable "BSKY_SEARCH_QUERIES" requires "BSKY_IDENTIFIER" and "BSKY_APP_PASSWORD", as search needs a logged in session`)
+	}
+	if len(c.Bluesky.SearchQueries) > 0 && c.Bluesky.SearchInterval <= 0 {
+		return fmt.Errorf(`environment variable "BSKY_SEARCH_INTERVAL" should be positive`)
+	}
+
+	switch strings.ToLower(c.Dedupe.Store) {
+	case "":
+	case SinkSQLite:
+		if c.SQLite.Path == "" {
+			return fmt.Errorf(`environment variable "SQLITE_PATH" should not be empty`)
+		}
+	default:
+		return fmt.Errorf("unknown DEDUPE_STORE %q, expected %q or nothing", c.Dedupe.Store, SinkSQLite)
+	}
+
+	for i, sink := range c.Sinks {
+		sink = strings.ToLower(strings.TrimSpace(sink))
+		c.Sinks[i] = sink
+
+		switch sink {
+		case SinkGSheets:
+			if c.Google.Credentials == "" {
+				return fmt.Errorf(`required environment variable "GOOGLE_APPLICATION_CREDENTIALS" is not set`)
+			}
+			if c.Google.SheetID == "" {
+				return fmt.Errorf(`environment variable "GOOGLE_SHEET_ID" should not be empty`)
+			}
+			if c.Google.BatchSize < 1 {
+				return fmt.Errorf(`environment variable "GOOGLE_BATCH_SIZE" should be at least 1`)
+			}
+		case SinkSQLite:
+			if c.SQLite.Path == "" {
+				return fmt.Errorf(`environment variable "SQLITE_PATH" should not be empty`)
+			}
+		case SinkJSONL, SinkCSV:
+			if c.File.Dir == "" {
+				return fmt.Errorf(`environment variable "FILE_SINK_DIR" should not be empty`)
+			}
+		default:
+			return fmt.Errorf("unknown sink %q in SINKS, expected any of %q", sink, []string{SinkGSheets, SinkSQLite, SinkJSONL, SinkCSV})
+		}
+	}
+	return nil
+}
+
+func (c *Config) GetLogLevel() slog.Level {
 	switch strings.ToLower(c.LogLevel) {
 	case "debug":
 		return slog.LevelDebug
@@ -48,6 +218,6 @@ func (c *Config) GetLogLevel() slog.Level{
 		return slog.LevelWarn
 	default:
 		return slog.LevelInfo
-		
+
 	}
 }
@@ -0,0 +1,328 @@
+package filesink
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/togdon/reply-bot/bot/pkg/post"
+	"github.com/togdon/reply-bot/bot/pkg/spreadsheet"
+)
+
+type Format string
+
+const (
+	JSONLines Format = "jsonl"
+	CSV       Format = "csv"
+
+	dayLayout = "2006-01-02"
+)
+
+var (
+	_ spreadsheet.Sink = (*Client)(nil)
+
+	csvHeader = []string{
+		"id", "uri", "type", "content", "source", "written_at",
+		"puzzle", "puzzle_date", "score", "guesses", "mistakes", "solved", "hard_mode", "solve_seconds",
+		"link_url", "link_section",
+	}
+)
+
+// record is the on-disk representation of a post.
+type record struct {
+	post.Post
+	WrittenAt time.Time `json:"written_at"`
+}
+
+// Client appends every post to a local file as JSON Lines or CSV, rotating
+// to a new file once the current one reaches MaxBytes and/or the UTC day
+// changes.
+type Client struct {
+	Dir      string
+	Prefix   string
+	Format   Format
+	MaxBytes int64
+	Daily    bool
+
+	logger *slog.Logger
+	now    func() time.Time
+
+	mu   sync.Mutex
+	file *os.File
+	size int64
+	day  string
+	seq  int
+}
+
+// NewClient returns a file sink writing <prefix>[-<day>][.<n>].<format>
+// files into dir, creating dir if needed. A maxBytes of zero disables size
+// based rotation.
+func NewClient(logger *slog.Logger, dir, prefix string, format Format, maxBytes int64, daily bool) (*Client, error) {
+	switch format {
+	case JSONLines, CSV:
+	default:
+		return nil, fmt.Errorf("unknown file sink format %q", format)
+	}
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("unable to create file sink directory: %w", err)
+	}
+
+	return &Client{
+		Dir:      dir,
+		Prefix:   prefix,
+		Format:   format,
+		MaxBytes: maxBytes,
+		Daily:    daily,
+		logger:   logger,
+		now:      time.Now,
+	}, nil
+}
+
+// Write implements spreadsheet.Sink by appending the post to the current file.
+func (c *Client) Write(_ context.Context, p post.Post) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.now().UTC()
+	line, err := c.encode(record{Post: p, WrittenAt: now})
+	if err != nil {
+		return err
+	}
+
+	if err := c.rotate(now, int64(len(line))); err != nil {
+		return err
+	}
+
+	n, err := c.file.Write(line)
+	c.size += int64(n)
+	if err != nil {
+		return fmt.Errorf("unable to write post to %s: %w", c.file.Name(), err)
+	}
+
+	return nil
+}
+
+// Close implements spreadsheet.Sink, syncing the current file to disk.
+func (c *Client) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.closeFile()
+}
+
+func (c *Client) encode(r record) ([]byte, error) {
+	switch c.Format {
+	case CSV:
+		fields := []string{
+			r.ID,
+			r.URI,
+			string(r.Type),
+			r.Content,
+			string(r.Source),
+			r.WrittenAt.Format(time.RFC3339),
+		}
+		fields = append(fields, gameFields(r.Game)...)
+		fields = append(fields, linkFields(r.Link)...)
+		return encodeCSV(fields)
+	default:
+		line, err := json.Marshal(r)
+		if err != nil {
+			return nil, fmt.Errorf("unable to encode post as json: %w", err)
+		}
+		return append(line, '\n'), nil
+	}
+}
+
+// gameFields formats the game columns of a CSV row, left empty for posts
+// that aren't game shares and for values the game doesn't report.
+func gameFields(g *post.GameResult) []string {
+	if g == nil {
+		return make([]string, 8)
+	}
+	return []string{
+		itoa(g.Puzzle),
+		g.Date,
+		g.Score,
+		itoa(g.Guesses),
+		format(g.Mistakes, strconv.Itoa),
+		format(g.Solved, strconv.FormatBool),
+		strconv.FormatBool(g.HardMode),
+		itoa(int(g.SolveTime.Seconds())),
+	}
+}
+
+// linkFields formats the link columns of a CSV row.
+func linkFields(l *post.Link) []string {
+	if l == nil {
+		return make([]string, 2)
+	}
+	return []string{l.URL, l.Section}
+}
+
+// itoa formats n, leaving zero empty.
+func itoa(n int) string {
+	if n == 0 {
+		return ""
+	}
+	return strconv.Itoa(n)
+}
+
+// format formats v, leaving nil empty.
+func format[T any](v *T, f func(T) string) string {
+	if v == nil {
+		return ""
+	}
+	return f(*v)
+}
+
+// rotate makes sure c.file is open and has room for n more bytes, moving on
+// to the next file when the day changes or the size limit would be exceeded.
+func (c *Client) rotate(now time.Time, n int64) error {
+	day := ""
+	if c.Daily {
+		day = now.Format(dayLayout)
+	}
+
+	switch {
+	case c.file == nil:
+		c.seq = 0
+	case day != c.day:
+		c.seq = 0
+	case c.MaxBytes > 0 && c.size > 0 && c.size+n > c.MaxBytes:
+		c.seq++
+	default:
+		return nil
+	}
+
+	if err := c.closeFile(); err != nil {
+		return err
+	}
+	c.day = day
+
+	// skip over files that are already full, e.g. after a restart, and CSV
+	// files written with a different header by an older version
+	for {
+		info, err := os.Stat(c.path())
+		if errors.Is(err, fs.ErrNotExist) {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("unable to stat %s: %w", c.path(), err)
+		}
+		full := c.MaxBytes > 0 && info.Size()+n > c.MaxBytes
+		if !full {
+			current, err := c.hasCurrentHeader(info.Size())
+			if err != nil {
+				return err
+			}
+			if current {
+				break
+			}
+		}
+		c.seq++
+	}
+
+	return c.openFile()
+}
+
+// hasCurrentHeader reports whether the file at c.path(), of the given size,
+// can be appended to: it is always true for JSON Lines and for empty files,
+// and otherwise true if the file starts with csvHeader.
+func (c *Client) hasCurrentHeader(size int64) (bool, error) {
+	if c.Format != CSV || size == 0 {
+		return true, nil
+	}
+
+	f, err := os.Open(c.path())
+	if err != nil {
+		return false, fmt.Errorf("unable to open %s: %w", c.path(), err)
+	}
+	defer f.Close()
+
+	header, err := csv.NewReader(f).Read()
+	if err != nil && !errors.Is(err, io.EOF) {
+		// an unreadable header is as good as a different one
+		return false, nil
+	}
+	return slices.Equal(header, csvHeader), nil
+}
+
+func (c *Client) path() string {
+	name := c.Prefix
+	if c.day != "" {
+		name += "-" + c.day
+	}
+	if c.seq > 0 {
+		name += fmt.Sprintf(".%d", c.seq)
+	}
+	return filepath.Join(c.Dir, name+"."+string(c.Format))
+}
+
+func (c *Client) openFile() error {
+	f, err := os.OpenFile(c.path(), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("unable to open file sink: %w", err)
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("unable to stat file sink: %w", err)
+	}
+
+	c.file = f
+	c.size = info.Size()
+
+	if c.Format == CSV && c.size == 0 {
+		header, err := encodeCSV(csvHeader)
+		if err != nil {
+			return err
+		}
+		n, err := f.Write(header)
+		c.size += int64(n)
+		if err != nil {
+			return fmt.Errorf("unable to write csv header: %w", err)
+		}
+	}
+
+	c.logger.Info("Writing posts to file", "path", f.Name())
+	return nil
+}
+
+func (c *Client) closeFile() error {
+	if c.file == nil {
+		return nil
+	}
+
+	f := c.file
+	c.file = nil
+
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return fmt.Errorf("unable to sync %s: %w", f.Name(), err)
+	}
+	return f.Close()
+}
+
+func encodeCSV(fields []string) ([]byte, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	if err := w.Write(fields); err != nil {
+		return nil, fmt.Errorf("unable to encode post as csv: %w", err)
+	}
+	w.Flush()
+	return buf.Bytes(), w.Error()
+}
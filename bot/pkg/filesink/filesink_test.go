@@ -0,0 +1,216 @@
+package filesink
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/togdon/reply-bot/bot/pkg/post"
+)
+
+var testPost = post.Post{
+	ID:      "1",
+	URI:     "https://example.com/1",
+	Content: "Wordle 1,236 4/6\n⬜🟧⬜⬜⬜",
+	Source:  post.Mastodon,
+	Type:    post.Wordle,
+}
+
+func newTestClient(t *testing.T, dir string, format Format, maxBytes int64, daily bool, now *time.Time) *Client {
+	t.Helper()
+	c, err := NewClient(slog.New(slog.NewTextHandler(io.Discard, nil)), dir, "posts", format, maxBytes, daily)
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+	c.now = func() time.Time { return *now }
+	return c
+}
+
+func files(t *testing.T, dir string) []string {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestWriteJSONLines(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Date(2024, 11, 6, 9, 0, 0, 0, time.UTC)
+	c := newTestClient(t, dir, JSONLines, 0, false, &now)
+
+	for i := 0; i < 2; i++ {
+		if err := c.Write(context.Background(), testPost); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+	}
+	if err := c.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	f, err := os.Open(filepath.Join(dir, "posts.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	var got []record
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var r record
+		if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+			t.Fatalf("invalid json line %q: %v", scanner.Text(), err)
+		}
+		got = append(got, r)
+	}
+
+	want := []record{{testPost, now}, {testPost, now}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("records = %v, want %v", got, want)
+	}
+}
+
+func TestWriteCSV(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Date(2024, 11, 6, 9, 0, 0, 0, time.UTC)
+	c := newTestClient(t, dir, CSV, 0, false, &now)
+
+	if err := c.Write(context.Background(), testPost); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	c.Close()
+
+	// reopening an existing file must not repeat the header
+	withMetadata := testPost
+	solved := true
+	withMetadata.Game = &post.GameResult{Puzzle: 1236, Date: "2024-11-06", Score: "4/6", Guesses: 4, Solved: &solved}
+	withMetadata.Link = &post.Link{URL: "https://nytimes.com/games/wordle/index.html", Section: "games"}
+	c = newTestClient(t, dir, CSV, 0, false, &now)
+	if err := c.Write(context.Background(), withMetadata); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	c.Close()
+
+	f, err := os.Open(filepath.Join(dir, "posts.csv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	got, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		t.Fatalf("invalid csv: %v", err)
+	}
+
+	row := []string{"1", "https://example.com/1", "wordle", testPost.Content, "mastodon", "2024-11-06T09:00:00Z"}
+	want := [][]string{
+		csvHeader,
+		append(row, "", "", "", "", "", "", "", "", "", ""),
+		append(row, "1236", "2024-11-06", "4/6", "4", "", "true", "false", "", "https://nytimes.com/games/wordle/index.html", "games"),
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("rows = %q, want %q", got, want)
+	}
+}
+
+func TestCSVHeaderChanged(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Date(2024, 11, 6, 9, 0, 0, 0, time.UTC)
+
+	// a file written by an older version with fewer columns
+	if err := os.WriteFile(filepath.Join(dir, "posts.csv"), []byte("id,uri,type,content,source,written_at\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	c := newTestClient(t, dir, CSV, 0, false, &now)
+	if err := c.Write(context.Background(), testPost); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	c.Close()
+
+	if got, want := files(t, dir), []string{"posts.1.csv", "posts.csv"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("files = %v, want %v", got, want)
+	}
+}
+
+func TestRotate(t *testing.T) {
+	line, err := json.Marshal(record{testPost, time.Date(2024, 11, 6, 9, 0, 0, 0, time.UTC)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	lineSize := int64(len(line) + 1)
+
+	tests := []struct {
+		name     string
+		maxBytes int64
+		daily    bool
+		writes   []time.Time
+		want     []string
+	}{
+		{
+			name:     "by size",
+			maxBytes: 2 * lineSize,
+			writes: []time.Time{
+				time.Date(2024, 11, 6, 9, 0, 0, 0, time.UTC),
+				time.Date(2024, 11, 6, 9, 0, 0, 0, time.UTC),
+				time.Date(2024, 11, 6, 9, 0, 0, 0, time.UTC),
+			},
+			want: []string{"posts.1.jsonl", "posts.jsonl"},
+		},
+		{
+			name:  "by day",
+			daily: true,
+			writes: []time.Time{
+				time.Date(2024, 11, 6, 9, 0, 0, 0, time.UTC),
+				time.Date(2024, 11, 6, 23, 0, 0, 0, time.UTC),
+				time.Date(2024, 11, 7, 0, 0, 0, 0, time.UTC),
+			},
+			want: []string{"posts-2024-11-06.jsonl", "posts-2024-11-07.jsonl"},
+		},
+		{
+			name:     "by size and day",
+			maxBytes: lineSize,
+			daily:    true,
+			writes: []time.Time{
+				time.Date(2024, 11, 6, 9, 0, 0, 0, time.UTC),
+				time.Date(2024, 11, 6, 9, 0, 0, 0, time.UTC),
+				time.Date(2024, 11, 7, 9, 0, 0, 0, time.UTC),
+			},
+			want: []string{"posts-2024-11-06.1.jsonl", "posts-2024-11-06.jsonl", "posts-2024-11-07.jsonl"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			var now time.Time
+			c := newTestClient(t, dir, JSONLines, tt.maxBytes, tt.daily, &now)
+			for _, at := range tt.writes {
+				now = at
+				if err := c.Write(context.Background(), testPost); err != nil {
+					t.Fatalf("Write() error = %v", err)
+				}
+			}
+			c.Close()
+
+			if got := files(t, dir); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("files = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,134 @@
+package gsheets
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+var errClosed = errors.New("gsheets client is closed")
+
+// queuedRow is a row waiting for the batcher, along with where to report
+// the outcome of the append that included it.
+type queuedRow struct {
+	tab  string
+	row  []interface{}
+	done chan error
+}
+
+// enqueue hands a row to the batcher and waits for it to be appended.
+func (c *Client) enqueue(ctx context.Context, tab string, row []interface{}) error {
+	c.startOnce.Do(func() {
+		go c.batch()
+	})
+
+	q := queuedRow{tab: tab, row: row, done: make(chan error, 1)}
+	select {
+	case c.queue <- q:
+	case <-c.stop:
+		return errClosed
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	select {
+	case err := <-q.done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// batch coalesces queued rows into a single append per tab once BatchSize
+// rows are waiting or FlushInterval has passed since the first of them
+// arrived.
+func (c *Client) batch() {
+	defer close(c.stopped)
+
+	var (
+		pending []queuedRow
+		timer   *time.Timer
+		flushC  <-chan time.Time
+	)
+
+	flush := func() {
+		if timer != nil {
+			timer.Stop()
+			timer, flushC = nil, nil
+		}
+		if len(pending) == 0 {
+			return
+		}
+
+		var (
+			tabs  []string
+			byTab = make(map[string][]queuedRow)
+		)
+		for _, q := range pending {
+			if _, ok := byTab[q.tab]; !ok {
+				tabs = append(tabs, q.tab)
+			}
+			byTab[q.tab] = append(byTab[q.tab], q)
+		}
+
+		// no caller's context covers the whole batch, so the flush gets its
+		// own, which Close cancels if it takes too long
+		ctx, cancel := context.WithTimeout(c.flushCtx, c.WriteTimeout)
+		defer cancel()
+
+		for _, tab := range tabs {
+			queued := byTab[tab]
+			rows := make([][]interface{}, len(queued))
+			for i, q := range queued {
+				rows[i] = q.row
+			}
+			err := c.append(ctx, tab, rows)
+			for _, q := range queued {
+				q.done <- err
+			}
+		}
+		pending = nil
+	}
+
+	for {
+		select {
+		case q := <-c.queue:
+			pending = append(pending, q)
+			if len(pending) >= c.BatchSize {
+				flush()
+			} else if timer == nil {
+				timer = time.NewTimer(c.FlushInterval)
+				flushC = timer.C
+			}
+		case <-flushC:
+			timer, flushC = nil, nil
+			flush()
+		case <-c.stop:
+			flush()
+			return
+		}
+	}
+}
+
+// closeBatcher flushes whatever the batcher is holding and stops it, giving
+// up on the flush after WriteTimeout (e.g. while backing off on quota
+// errors). If the batcher never started it is simply marked as stopped.
+func (c *Client) closeBatcher() {
+	c.closeOnce.Do(func() {
+		defer c.cancelFlush()
+
+		c.startOnce.Do(func() {
+			close(c.stopped)
+		})
+		close(c.stop)
+
+		timer := time.NewTimer(c.WriteTimeout)
+		defer timer.Stop()
+		select {
+		case <-c.stopped:
+		case <-timer.C:
+			c.cancelFlush()
+			<-c.stopped
+		}
+	})
+}
@@ -0,0 +1,454 @@
+package gsheets
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"google.golang.org/api/sheets/v4"
+
+	"github.com/togdon/reply-bot/bot/pkg/post"
+)
+
+const (
+	FieldID         = "id"
+	FieldURI        = "uri"
+	FieldType       = "type"
+	FieldContent    = "content"
+	FieldSource     = "source"
+	FieldResponded  = "responded"
+	FieldDetectedAt = "detected_at"
+	FieldAuthor     = "author"
+	FieldLanguage   = "language"
+	FieldRule       = "rule"
+
+	FieldPuzzle       = "puzzle"
+	FieldPuzzleDate   = "puzzle_date"
+	FieldScore        = "score"
+	FieldGuesses      = "guesses"
+	FieldMistakes     = "mistakes"
+	FieldSolved       = "solved"
+	FieldHardMode     = "hard_mode"
+	FieldSolveSeconds = "solve_seconds"
+	FieldLinkURL      = "link_url"
+	FieldLinkSection  = "link_section"
+
+	// detectedAtLayout is a format Sheets recognises as a date and time when
+	// entered, so the column can be sorted.
+	detectedAtLayout = "2006-01-02 15:04:05"
+)
+
+// Column maps a post field onto a sheet column with the given header.
+type Column struct {
+	Header string
+	Field  string
+}
+
+// field describes how a post field is written to and read back from a cell.
+type field struct {
+	header string
+	value  func(post.Post) interface{}
+	parse  func(p *post.Post, value string) error
+}
+
+var fields = map[string]field{
+	FieldID: {
+		header: "ID",
+		value:  func(p post.Post) interface{} { return p.ID },
+		parse:  func(p *post.Post, v string) error { p.ID = v; return nil },
+	},
+	FieldURI: {
+		header: "URI",
+		value:  func(p post.Post) interface{} { return p.URI },
+		parse:  func(p *post.Post, v string) error { p.URI = v; return nil },
+	},
+	FieldType: {
+		header: "Type",
+		value:  func(p post.Post) interface{} { return p.Type },
+		parse:  func(p *post.Post, v string) error { p.Type = post.NYTContentType(v); return nil },
+	},
+	FieldContent: {
+		header: "Content",
+		value:  func(p post.Post) interface{} { return p.Content },
+		parse:  func(p *post.Post, v string) error { p.Content = v; return nil },
+	},
+	FieldSource: {
+		header: "Source",
+		value:  func(p post.Post) interface{} { return p.Source },
+		parse:  func(p *post.Post, v string) error { p.Source = post.APISource(v); return nil },
+	},
+	// the Responded checkbox belongs to the sheet rather than the post, so it
+	// is always written unticked and read back separately
+	FieldResponded: {
+		header: "Responded",
+		value:  func(post.Post) interface{} { return false },
+	},
+	FieldDetectedAt: {
+		header: "Detected At",
+		value: func(p post.Post) interface{} {
+			if p.DetectedAt.IsZero() {
+				return ""
+			}
+			return p.DetectedAt.UTC().Format(detectedAtLayout)
+		},
+		parse: func(p *post.Post, v string) error {
+			if v == "" {
+				return nil
+			}
+			t, err := parseDateTime(v)
+			if err != nil {
+				return err
+			}
+			p.DetectedAt = t
+			return nil
+		},
+	},
+	FieldAuthor: {
+		header: "Author",
+		value:  func(p post.Post) interface{} { return p.Author },
+		parse:  func(p *post.Post, v string) error { p.Author = v; return nil },
+	},
+	FieldLanguage: {
+		header: "Language",
+		value:  func(p post.Post) interface{} { return p.Language },
+		parse:  func(p *post.Post, v string) error { p.Language = v; return nil },
+	},
+	FieldRule: {
+		header: "Rule",
+		value:  func(p post.Post) interface{} { return p.Rule },
+		parse:  func(p *post.Post, v string) error { p.Rule = v; return nil },
+	},
+	// game and link fields are left blank for posts without them, so the
+	// sheet can be sorted and filtered by puzzle
+	FieldPuzzle: {
+		header: "Puzzle",
+		value:  gameValue(func(g *post.GameResult) interface{} { return blankIfZero(g.Puzzle) }),
+		parse:  parseGameInt(func(g *post.GameResult, n int) { g.Puzzle = n }),
+	},
+	FieldPuzzleDate: {
+		header: "Puzzle Date",
+		value:  gameValue(func(g *post.GameResult) interface{} { return literal(g.Date) }),
+		parse:  parseGame(parsePuzzleDate),
+	},
+	FieldScore: {
+		header: "Score",
+		value:  gameValue(func(g *post.GameResult) interface{} { return literal(g.Score) }),
+		parse:  parseGame(func(g *post.GameResult, v string) error { g.Score = v; return nil }),
+	},
+	FieldGuesses: {
+		header: "Guesses",
+		value:  gameValue(func(g *post.GameResult) interface{} { return blankIfZero(g.Guesses) }),
+		parse:  parseGameInt(func(g *post.GameResult, n int) { g.Guesses = n }),
+	},
+	FieldMistakes: {
+		header: "Mistakes",
+		value:  gameValue(func(g *post.GameResult) interface{} { return blankIfNil(g.Mistakes) }),
+		parse:  parseGameInt(func(g *post.GameResult, n int) { g.Mistakes = &n }),
+	},
+	FieldSolved: {
+		header: "Solved",
+		value:  gameValue(func(g *post.GameResult) interface{} { return blankIfNil(g.Solved) }),
+		parse:  parseGameBool(func(g *post.GameResult, b bool) { g.Solved = &b }),
+	},
+	FieldHardMode: {
+		header: "Hard Mode",
+		value:  gameValue(func(g *post.GameResult) interface{} { return g.HardMode }),
+		parse:  parseGameBool(func(g *post.GameResult, b bool) { g.HardMode = b }),
+	},
+	FieldSolveSeconds: {
+		header: "Solve Seconds",
+		value:  gameValue(func(g *post.GameResult) interface{} { return blankIfZero(int(g.SolveTime.Seconds())) }),
+		parse:  parseGameInt(func(g *post.GameResult, n int) { g.SolveTime = time.Duration(n) * time.Second }),
+	},
+	FieldLinkURL: {
+		header: "Link",
+		value: func(p post.Post) interface{} {
+			if p.Link == nil {
+				return ""
+			}
+			return p.Link.URL
+		},
+		parse: func(p *post.Post, v string) error {
+			if v != "" {
+				link(p).URL = v
+			}
+			return nil
+		},
+	},
+	FieldLinkSection: {
+		header: "Section",
+		value: func(p post.Post) interface{} {
+			if p.Link == nil {
+				return ""
+			}
+			return p.Link.Section
+		},
+		parse: func(p *post.Post, v string) error {
+			if v != "" {
+				link(p).Section = v
+			}
+			return nil
+		},
+	},
+}
+
+// gameValue returns a field value func reading from the post's game
+// result, blank for posts without one.
+func gameValue(value func(*post.GameResult) interface{}) func(post.Post) interface{} {
+	return func(p post.Post) interface{} {
+		if p.Game == nil {
+			return ""
+		}
+		return value(p.Game)
+	}
+}
+
+// parseGame returns a field parse func setting part of the post's game
+// result, creating it on the first non-blank cell.
+func parseGame(parse func(*post.GameResult, string) error) func(*post.Post, string) error {
+	return func(p *post.Post, v string) error {
+		if v == "" {
+			return nil
+		}
+		if p.Game == nil {
+			p.Game = &post.GameResult{}
+		}
+		return parse(p.Game, v)
+	}
+}
+
+func parseGameInt(set func(*post.GameResult, int)) func(*post.Post, string) error {
+	return parseGame(func(g *post.GameResult, v string) error {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return err
+		}
+		set(g, n)
+		return nil
+	})
+}
+
+func parseGameBool(set func(*post.GameResult, bool)) func(*post.Post, string) error {
+	return parseGame(func(g *post.GameResult, v string) error {
+		b, err := parseBool(v)
+		if err != nil {
+			return err
+		}
+		set(g, b)
+		return nil
+	})
+}
+
+// link returns the post's link, creating it if needed.
+func link(p *post.Post) *post.Link {
+	if p.Link == nil {
+		p.Link = &post.Link{}
+	}
+	return p.Link
+}
+
+// literal keeps Sheets from reading s as anything but text, e.g. a Wordle
+// score of 4/6 as the 6th of April, when appended with USER_ENTERED.
+func literal(s string) interface{} {
+	if s == "" {
+		return ""
+	}
+	return "'" + s
+}
+
+// parsePuzzleDate reads a puzzle date, which rows written before it was
+// appended as text hold as a date serial number.
+func parsePuzzleDate(g *post.GameResult, v string) error {
+	if _, err := strconv.ParseFloat(v, 64); err == nil {
+		t, err := parseDateTime(v)
+		if err != nil {
+			return err
+		}
+		v = t.Format(time.DateOnly)
+	}
+	g.Date = v
+	return nil
+}
+
+// sheetsEpoch is day zero of the serial numbers Sheets stores dates as.
+var sheetsEpoch = time.Date(1899, time.December, 30, 0, 0, 0, 0, time.UTC)
+
+// parseDateTime parses a cell written in detectedAtLayout. Sheets stores it
+// as a date, so unformatted it reads back as a serial number of days since
+// sheetsEpoch, with the time of day as the fraction.
+func parseDateTime(v string) (time.Time, error) {
+	if days, err := strconv.ParseFloat(v, 64); err == nil {
+		return sheetsEpoch.Add(time.Duration(days * float64(24*time.Hour))).Round(time.Second), nil
+	}
+	return time.Parse(detectedAtLayout, v)
+}
+
+func blankIfZero(n int) interface{} {
+	if n == 0 {
+		return ""
+	}
+	return n
+}
+
+func blankIfNil[T any](v *T) interface{} {
+	if v == nil {
+		return ""
+	}
+	return *v
+}
+
+// DefaultColumns is the layout the bot has always written: ID, URI, Type,
+// Content, Source and the Responded checkbox in columns A to F.
+var DefaultColumns = []Column{
+	{Header: "ID", Field: FieldID},
+	{Header: "URI", Field: FieldURI},
+	{Header: "Type", Field: FieldType},
+	{Header: "Content", Field: FieldContent},
+	{Header: "Source", Field: FieldSource},
+	{Header: "Responded", Field: FieldResponded},
+}
+
+// ParseColumns parses a comma separated column layout, starting at column
+// A. Each entry is either a field name, using the field's default header,
+// or "Header=field". Valid fields are id, uri, type, content, source,
+// responded, detected_at, author, language, rule, puzzle, puzzle_date,
+// score, guesses, mistakes, solved, hard_mode, solve_seconds, link_url and
+// link_section; id is required.
+func ParseColumns(spec string) ([]Column, error) {
+	if strings.TrimSpace(spec) == "" {
+		return DefaultColumns, nil
+	}
+
+	var (
+		columns []Column
+		seen    = make(map[string]bool)
+	)
+	for _, entry := range strings.Split(spec, ",") {
+		header, name, ok := strings.Cut(entry, "=")
+		if !ok {
+			name, header = header, ""
+		}
+		header = strings.TrimSpace(header)
+		name = strings.ToLower(strings.TrimSpace(name))
+
+		f, ok := fields[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown column field %q", name)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("column field %q is used more than once", name)
+		}
+		seen[name] = true
+
+		if header == "" {
+			header = f.header
+		}
+		columns = append(columns, Column{Header: header, Field: name})
+	}
+
+	if !seen[FieldID] {
+		return nil, fmt.Errorf("column layout must include the %q field", FieldID)
+	}
+	return columns, nil
+}
+
+// ValidateHeader checks that the header row of every tab the client writes
+// to matches Columns, writing the header into tabs whose first row is empty.
+// Tabs created later by PerType routing get the header when they are
+// created.
+func (c *Client) ValidateHeader(ctx context.Context) error {
+	tabs, err := c.knownTabs(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, tab := range tabs {
+		if err := c.validateTabHeader(ctx, tab); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *Client) validateTabHeader(ctx context.Context, tab string) error {
+	var resp *sheets.ValueRange
+	err := c.call(ctx, func() error {
+		var err error
+		resp, err = c.Service.Spreadsheets.Values.Get(c.SheetID, a1Range(tab, "1:1")).Context(ctx).Do()
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("unable to read header row of tab %q: %w", tab, err)
+	}
+
+	var got []interface{}
+	if len(resp.Values) > 0 {
+		got = resp.Values[0]
+	}
+	if len(got) == 0 {
+		c.logger.Info("Writing header row", "tab", tab)
+		return c.writeHeader(ctx, tab)
+	}
+
+	var mismatches []string
+	for i, col := range c.Columns {
+		if actual := cell(got, i); !strings.EqualFold(strings.TrimSpace(actual), col.Header) {
+			mismatches = append(mismatches, fmt.Sprintf("column %s is %q, want %q", columnName(i), actual, col.Header))
+		}
+	}
+	if len(mismatches) > 0 {
+		return fmt.Errorf("header row of tab %q does not match the column layout: %s", tab, strings.Join(mismatches, "; "))
+	}
+	return nil
+}
+
+func (c *Client) writeHeader(ctx context.Context, tab string) error {
+	headers := make([]interface{}, len(c.Columns))
+	for i, col := range c.Columns {
+		headers[i] = col.Header
+	}
+
+	err := c.call(ctx, func() error {
+		_, err := c.Service.Spreadsheets.Values.Update(c.SheetID, a1Range(tab, "A1:"+columnName(len(c.Columns)-1)+"1"), &sheets.ValueRange{
+			Values: [][]interface{}{headers},
+		}).ValueInputOption("RAW").Context(ctx).Do()
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("unable to write header row to tab %q: %w", tab, err)
+	}
+	return nil
+}
+
+// row formats a post according to Columns.
+func (c *Client) row(p post.Post) []interface{} {
+	values := make([]interface{}, len(c.Columns))
+	for i, col := range c.Columns {
+		values[i] = fields[col.Field].value(p)
+	}
+	return values
+}
+
+// columnRange returns the A1 notation covering every column, e.g. "'test'!A:F".
+func (c *Client) columnRange(tab string) string {
+	return a1Range(tab, "A:"+columnName(len(c.Columns)-1))
+}
+
+// a1Range returns the A1 notation for cells within tab. The tab name is
+// always quoted, with any quote in it doubled, so names with spaces,
+// hyphens or other punctuation are not misread as part of the range.
+func a1Range(tab, cells string) string {
+	return "'" + strings.ReplaceAll(tab, "'", "''") + "'!" + cells
+}
+
+// columnName converts a 0-based column index into its letters, e.g. 0 is A
+// and 27 is AB.
+func columnName(i int) string {
+	name := ""
+	for i++; i > 0; i = (i - 1) / 26 {
+		name = string(rune('A'+(i-1)%26)) + name
+	}
+	return name
+}
@@ -0,0 +1,217 @@
+package gsheets
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/togdon/reply-bot/bot/pkg/post"
+)
+
+func TestParseColumns(t *testing.T) {
+	tests := []struct {
+		name    string
+		spec    string
+		want    []Column
+		wantErr bool
+	}{
+		{name: "empty uses the default layout", spec: "", want: DefaultColumns},
+		{
+			name: "default and custom headers",
+			spec: "id, Link=uri,detected_at, Handled?=Responded",
+			want: []Column{
+				{Header: "ID", Field: FieldID},
+				{Header: "Link", Field: FieldURI},
+				{Header: "Detected At", Field: FieldDetectedAt},
+				{Header: "Handled?", Field: FieldResponded},
+			},
+		},
+		{name: "unknown field", spec: "id,likes", wantErr: true},
+		{name: "repeated field", spec: "id,uri,URL=uri", wantErr: true},
+		{name: "missing id", spec: "uri,type", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseColumns(tt.spec)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseColumns() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseColumns() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestColumnName(t *testing.T) {
+	for i, want := range map[int]string{0: "A", 5: "F", 25: "Z", 26: "AA", 27: "AB", 701: "ZZ", 702: "AAA"} {
+		if got := columnName(i); got != want {
+			t.Errorf("columnName(%d) = %q, want %q", i, got, want)
+		}
+	}
+}
+
+func TestA1Range(t *testing.T) {
+	tests := []struct {
+		tab, cells, want string
+	}{
+		{"test", "A:F", "'test'!A:F"},
+		{"NYT posts", "1:1", "'NYT posts'!1:1"},
+		{"spelling-bee", "A1:F1", "'spelling-bee'!A1:F1"},
+		{"Bob's posts", "A:F", "'Bob''s posts'!A:F"},
+	}
+	for _, tt := range tests {
+		if got := a1Range(tt.tab, tt.cells); got != tt.want {
+			t.Errorf("a1Range(%q, %q) = %q, want %q", tt.tab, tt.cells, got, tt.want)
+		}
+	}
+}
+
+// userEntered returns row as Sheets stores it when appended with
+// USER_ENTERED, without the quote that marks a cell as text.
+func userEntered(row []interface{}) []interface{} {
+	stored := make([]interface{}, len(row))
+	for i, v := range row {
+		if s, ok := v.(string); ok {
+			v = strings.TrimPrefix(s, "'")
+		}
+		stored[i] = v
+	}
+	return stored
+}
+
+func TestCustomColumnsRoundTrip(t *testing.T) {
+	columns, err := ParseColumns("detected_at,id,author,language,rule,type,uri,responded")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := &Client{Columns: columns}
+
+	p := post.Post{
+		ID:         "https://mastodon.social/@someone/1",
+		URI:        "https://mastodon.social/@someone/1",
+		Type:       post.Wordle,
+		DetectedAt: time.Date(2024, 11, 6, 9, 30, 0, 0, time.UTC),
+		Author:     "someone@mastodon.social",
+		Language:   "en",
+		Rule:       "wordle",
+	}
+
+	row := c.row(p)
+	if got := c.columnRange("test"); got != "'test'!A:H" {
+		t.Errorf("columnRange() = %q, want %q", got, "'test'!A:H")
+	}
+	if got, want := row[0], "2024-11-06 09:30:00"; got != want {
+		t.Errorf("detected_at cell = %v, want %v", got, want)
+	}
+
+	rows, err := parseRows(discard, [][]interface{}{userEntered(row)}, columns)
+	if err != nil {
+		t.Fatalf("parseRows() error = %v", err)
+	}
+	if want := []Row{{Number: 1, Post: p}}; !reflect.DeepEqual(rows, want) {
+		t.Errorf("parseRows() = %+v, want %+v", rows, want)
+	}
+}
+
+func TestMetadataColumnsRoundTrip(t *testing.T) {
+	columns, err := ParseColumns("id,type,puzzle,puzzle_date,score,guesses,mistakes,solved,hard_mode,solve_seconds,link_url,link_section")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := &Client{Columns: columns}
+
+	solved, mistakes := true, 0
+	tests := []post.Post{
+		{
+			ID:   "1",
+			Type: post.Wordle,
+			Game: &post.GameResult{Puzzle: 1236, Date: "2024-11-06", Score: "4/6", Guesses: 4, Solved: &solved, HardMode: true},
+		},
+		{
+			ID:   "2",
+			Type: post.Crossword,
+			Game: &post.GameResult{Date: "2024-10-16", SolveTime: 8*time.Minute + 12*time.Second},
+		},
+		{
+			ID:   "3",
+			Type: post.Connections,
+			Game: &post.GameResult{Puzzle: 523, Date: "2024-11-15", Mistakes: &mistakes, Solved: &solved},
+		},
+		{
+			ID:   "4",
+			Type: post.Article,
+			Link: &post.Link{URL: "https://nytimes.com/2024/10/16/us/example.html", Section: "us"},
+		},
+	}
+
+	for _, p := range tests {
+		t.Run(string(p.Type), func(t *testing.T) {
+			rows, err := parseRows(discard, [][]interface{}{userEntered(c.row(p))}, columns)
+			if err != nil {
+				t.Fatalf("parseRows() error = %v", err)
+			}
+			if want := []Row{{Number: 1, Post: p}}; !reflect.DeepEqual(rows, want) {
+				t.Errorf("parseRows() = %+v, want %+v", rows[0].Post, p)
+			}
+		})
+	}
+}
+
+func TestMetadataTextCells(t *testing.T) {
+	columns, err := ParseColumns("id,puzzle_date,score")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := &Client{Columns: columns}
+
+	row := c.row(post.Post{ID: "1", Game: &post.GameResult{Date: "2024-11-06", Score: "4/6"}})
+	if want := []interface{}{"1", "'2024-11-06", "'4/6"}; !reflect.DeepEqual(row, want) {
+		t.Errorf("row() = %q, want %q so Sheets keeps the cells as text", row, want)
+	}
+
+	// rows appended before the puzzle date was written as text hold it as
+	// a date, read back unformatted as a serial number
+	rows, err := parseRows(discard, [][]interface{}{{"1", float64(45602), "4/6"}}, columns)
+	if err != nil {
+		t.Fatalf("parseRows() error = %v", err)
+	}
+	if len(rows) != 1 || rows[0].Post.Game == nil || rows[0].Post.Game.Date != "2024-11-06" {
+		t.Errorf("parseRows() = %+v, want puzzle date 2024-11-06", rows)
+	}
+}
+
+func TestValidateHeader(t *testing.T) {
+	tests := []struct {
+		name        string
+		header      []interface{}
+		wantErr     bool
+		wantWritten bool
+	}{
+		{name: "matching, ignoring case", header: []interface{}{"id", "URI", "Type", "Content", "Source", "Responded"}},
+		{name: "empty sheet gets the header", wantWritten: true},
+		{name: "mismatch", header: []interface{}{"ID", "URL", "Type", "Content", "Source", "Responded"}, wantErr: true},
+		{name: "missing columns", header: []interface{}{"ID", "URI"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := &fakeSheets{headers: map[string][]interface{}{}}
+			if tt.header != nil {
+				fake.values = [][]interface{}{tt.header}
+			}
+			c := newTestClient(t, fake)
+
+			err := c.ValidateHeader(context.Background())
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ValidateHeader() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if _, written := fake.headers["test"]; written != tt.wantWritten {
+				t.Errorf("header written = %v, want %v", written, tt.wantWritten)
+			}
+		})
+	}
+}
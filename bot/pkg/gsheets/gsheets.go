@@ -2,25 +2,70 @@ package gsheets
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"log"
 	"log/slog"
+	"net/http"
+	"sync"
+	"time"
 
+	"google.golang.org/api/googleapi"
 	"google.golang.org/api/option"
 	"google.golang.org/api/sheets/v4"
 
 	"github.com/togdon/reply-bot/bot/pkg/post"
+	"github.com/togdon/reply-bot/bot/pkg/spreadsheet"
 )
 
 const (
-	SHEET_ID   = "1wD8zsIcn9vUPmL749MFAreXx8cfaYeqRfFoGuSnJ2Lk"
+	// appendInterval keeps appends at 60 per minute, the default Sheets
+	// write quota per user.
+	appendInterval = time.Second
+	maxRetries     = 5
+	retryBackoff   = 5 * time.Second
+	writeTimeout   = 30 * time.Second
 )
 
+var _ spreadsheet.BatchSink = (*Client)(nil)
+
 // GSheetsClient encapsulates the Sheets service and sheet configuration.
+//
+// With a BatchSize above one, concurrent Writes are coalesced into a single
+// append of up to BatchSize rows, waiting at most FlushInterval for a batch
+// to fill. Each of those appends, including the last one made by Close, is
+// given up after WriteTimeout.
+//
+// With PerType set, each post goes to a tab named after its
+// post.NYTContentType (posts without a type still go to SheetName), and
+// missing tabs are created with a header row.
+//
+// Columns lays out each row, starting at column A.
 type Client struct {
-	Service   *sheets.Service
-	SheetID   string
-	SheetName string
+	Service       *sheets.Service
+	SheetID       string
+	SheetName     string
+	BatchSize     int
+	FlushInterval time.Duration
+	WriteTimeout  time.Duration
+	PerType       bool
+	Columns       []Column
+
+	logger  *slog.Logger
+	limiter *rateLimiter
+
+	// tabs maps every tab known to exist to whether it has its header
+	// row, which is false for a tab created here until the header has been
+	// written.
+	tabsMu sync.Mutex
+	tabs   map[string]bool
+
+	startOnce   sync.Once
+	closeOnce   sync.Once
+	queue       chan queuedRow
+	stop        chan struct{}
+	stopped     chan struct{}
+	flushCtx    context.Context
+	cancelFlush context.CancelFunc
 }
 
 // NewGSheetsClient initializes a Google Sheets API client and returns a GSheetsClient instance.
@@ -30,35 +75,136 @@ func NewGSheetsClient(ctx context.Context, logger *slog.Logger, creds []byte, sh
 		return nil, fmt.Errorf("unable to create Sheets client: %v", err)
 	}
 
+	return newClient(logger, service, sheetID, sheetName), nil
+}
+
+func newClient(logger *slog.Logger, service *sheets.Service, sheetID, sheetName string) *Client {
+	flushCtx, cancelFlush := context.WithCancel(context.Background())
 	return &Client{
-		Service:   service,
-		SheetID:   sheetID,
-		SheetName: sheetName,
-	}, nil
+		Service:       service,
+		SheetID:       sheetID,
+		SheetName:     sheetName,
+		BatchSize:     1,
+		FlushInterval: time.Second,
+		WriteTimeout:  writeTimeout,
+		Columns:       DefaultColumns,
+		logger:        logger,
+		limiter:       &rateLimiter{interval: appendInterval, now: time.Now},
+		queue:         make(chan queuedRow),
+		stop:          make(chan struct{}),
+		stopped:       make(chan struct{}),
+		flushCtx:      flushCtx,
+		cancelFlush:   cancelFlush,
+	}
 }
 
-// AppendRow adds a new entry to the Google Sheet, formatted with URL, Post Type, and Responded checkbox.
+// AppendRow adds a new entry to the Google Sheet, formatted according to Columns.
 func (c *Client) AppendRow(post post.Post) error {
-	rowData := []interface{}{
-		post.ID,
-		post.URI,
-		post.Type,
-		post.Content,
-		post.Source,
-		false,
+	return c.Write(context.Background(), post)
+}
+
+// Write implements spreadsheet.Sink by appending the post as a new row.
+func (c *Client) Write(ctx context.Context, post post.Post) error {
+	if c.BatchSize > 1 {
+		return c.enqueue(ctx, c.tabFor(post), c.row(post))
+	}
+	return c.append(ctx, c.tabFor(post), [][]interface{}{c.row(post)})
+}
+
+// WriteBatch implements spreadsheet.BatchSink, appending the posts to each
+// tab in chunks of at most BatchSize rows. If a chunk fails after others
+// were appended, the error is a *spreadsheet.BatchError naming the posts
+// already in the sheet.
+func (c *Client) WriteBatch(ctx context.Context, posts []post.Post) error {
+	var (
+		tabs  []string
+		byTab = make(map[string][]int)
+		size  = max(c.BatchSize, 1)
+	)
+	for i, p := range posts {
+		tab := c.tabFor(p)
+		if _, ok := byTab[tab]; !ok {
+			tabs = append(tabs, tab)
+		}
+		byTab[tab] = append(byTab[tab], i)
+	}
+
+	var written []int
+	for _, tab := range tabs {
+		indexes := byTab[tab]
+		for start := 0; start < len(indexes); start += size {
+			chunk := indexes[start:min(start+size, len(indexes))]
+			rows := make([][]interface{}, len(chunk))
+			for j, i := range chunk {
+				rows[j] = c.row(posts[i])
+			}
+			if err := c.append(ctx, tab, rows); err != nil {
+				if len(written) > 0 {
+					return &spreadsheet.BatchError{Written: written, Err: err}
+				}
+				return err
+			}
+			written = append(written, chunk...)
+		}
 	}
+	return nil
+}
 
-	writeRange := fmt.Sprintf("%s!A:F", c.SheetName) // Columns A to F
+// Close implements spreadsheet.Sink, appending any rows still waiting for
+// a batch to fill.
+func (c *Client) Close() error {
+	c.closeBatcher()
+	return nil
+}
+
+// append adds rows to the end of a tab in a single request.
+func (c *Client) append(ctx context.Context, tab string, rows [][]interface{}) error {
+	if c.PerType {
+		if err := c.ensureTab(ctx, tab); err != nil {
+			return err
+		}
+	}
 
-	// Append data to the specified range in the sheet
-	resp, err := c.Service.Spreadsheets.Values.Append(c.SheetID, writeRange, &sheets.ValueRange{
-		Values: [][]interface{}{rowData},
-	}).ValueInputOption("USER_ENTERED").Do()
+	writeRange := c.columnRange(tab)
 
-	if err != nil || resp.HTTPStatusCode != 200 {
-		return fmt.Errorf("unable to append data to sheet: %v, status code: %d", err, resp.HTTPStatusCode)
+	err := c.call(ctx, func() error {
+		// Append data to the specified range in the sheet
+		resp, err := c.Service.Spreadsheets.Values.Append(c.SheetID, writeRange, &sheets.ValueRange{
+			Values: rows,
+		}).ValueInputOption("USER_ENTERED").Context(ctx).Do()
+		if err != nil {
+			return err
+		}
+		if resp.HTTPStatusCode != 200 {
+			return fmt.Errorf("status code: %d", resp.HTTPStatusCode)
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("unable to append data to sheet: %w", err)
 	}
 
-	log.Println("Row successfully appended.")
+	c.logger.Info("Rows successfully appended", "tab", tab, "rows", len(rows))
 	return nil
 }
+
+// call makes a Sheets API request once the rate limiter allows it, retrying
+// when the Sheets quota is exhausted.
+func (c *Client) call(ctx context.Context, fn func() error) error {
+	for attempt := 0; ; attempt++ {
+		if err := c.limiter.wait(ctx); err != nil {
+			return err
+		}
+
+		err := fn()
+
+		var apiErr *googleapi.Error
+		if errors.As(err, &apiErr) && apiErr.Code == http.StatusTooManyRequests && attempt < maxRetries {
+			delay := retryAfter(apiErr.Header, time.Now(), retryBackoff<<attempt)
+			c.logger.Warn("Sheets quota exceeded, backing off", "delay", delay, "attempt", attempt+1)
+			c.limiter.pause(delay)
+			continue
+		}
+		return err
+	}
+}
@@ -0,0 +1,410 @@
+package gsheets
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"google.golang.org/api/option"
+	"google.golang.org/api/sheets/v4"
+
+	"github.com/togdon/reply-bot/bot/pkg/post"
+	"github.com/togdon/reply-bot/bot/pkg/spreadsheet"
+)
+
+// fakeSheets is a stand-in for the Sheets API that records the size of
+// every append and can be told to answer with 429s first. Like the real API
+// it refuses to add a tab that already exists.
+type fakeSheets struct {
+	mu         sync.Mutex
+	appends    []int
+	appendTabs []string
+	throttle   int
+	retryAfter string
+	values     [][]interface{}
+	tabs       map[string]bool
+	headers    map[string][]interface{}
+
+	// failHeaders is the number of header writes that fail, and addSheets
+	// counts the tabs created. Appends to failTab always fail.
+	failHeaders int
+	addSheets   int
+	failTab     string
+}
+
+// tab returns the tab named in a values request path such as
+// /v4/spreadsheets/sheet-id/values/'wordle'!A:F:append.
+func tab(r *http.Request) string {
+	_, rng, _ := strings.Cut(r.URL.Path, "/values/")
+	name := rng[:strings.LastIndex(rng, "!")]
+	name = strings.TrimSuffix(strings.TrimPrefix(name, "'"), "'")
+	return strings.ReplaceAll(name, "''", "'")
+}
+
+func (f *fakeSheets) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.throttle > 0 {
+		f.throttle--
+		w.Header().Set("Retry-After", cmp.Or(f.retryAfter, "0"))
+		w.WriteHeader(http.StatusTooManyRequests)
+		io.WriteString(w, `{"error": {"code": 429, "message": "Quota exceeded"}}`)
+		return
+	}
+
+	switch {
+	case r.Method == http.MethodGet && strings.Contains(r.URL.Path, "/values/"):
+		json.NewEncoder(w).Encode(sheets.ValueRange{Values: f.values})
+		return
+	case r.Method == http.MethodGet:
+		var resp sheets.Spreadsheet
+		for name := range f.tabs {
+			resp.Sheets = append(resp.Sheets, &sheets.Sheet{Properties: &sheets.SheetProperties{Title: name}})
+		}
+		json.NewEncoder(w).Encode(resp)
+		return
+	case strings.HasSuffix(r.URL.Path, ":batchUpdate"):
+		var req sheets.BatchUpdateSpreadsheetRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		for _, r := range req.Requests {
+			title := r.AddSheet.Properties.Title
+			if f.tabs[title] {
+				w.WriteHeader(http.StatusBadRequest)
+				fmt.Fprintf(w, `{"error": {"code": 400, "message": "Invalid requests[0].addSheet: A sheet with the name \"%s\" already exists."}}`, title)
+				return
+			}
+			f.tabs[title] = true
+			f.addSheets++
+		}
+		io.WriteString(w, `{}`)
+		return
+	case r.Method == http.MethodPut:
+		if f.failHeaders > 0 {
+			f.failHeaders--
+			http.Error(w, "backend error", http.StatusInternalServerError)
+			return
+		}
+		var vr sheets.ValueRange
+		json.NewDecoder(r.Body).Decode(&vr)
+		f.headers[tab(r)] = vr.Values[0]
+		io.WriteString(w, `{}`)
+		return
+	case !strings.HasSuffix(r.URL.Path, ":append"):
+		http.NotFound(w, r)
+		return
+	}
+
+	if f.failTab != "" && tab(r) == f.failTab {
+		http.Error(w, "backend error", http.StatusInternalServerError)
+		return
+	}
+
+	var vr sheets.ValueRange
+	if err := json.NewDecoder(r.Body).Decode(&vr); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	f.appends = append(f.appends, len(vr.Values))
+	f.appendTabs = append(f.appendTabs, tab(r))
+	io.WriteString(w, `{}`)
+}
+
+func (f *fakeSheets) batches() []int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]int(nil), f.appends...)
+}
+
+func newTestClient(t *testing.T, fake *fakeSheets) *Client {
+	t.Helper()
+	srv := httptest.NewServer(fake)
+	t.Cleanup(srv.Close)
+
+	service, err := sheets.NewService(context.Background(),
+		option.WithEndpoint(srv.URL+"/"),
+		option.WithHTTPClient(srv.Client()),
+		option.WithoutAuthentication(),
+	)
+	if err != nil {
+		t.Fatalf("sheets.NewService() error = %v", err)
+	}
+
+	c := newClient(slog.New(slog.NewTextHandler(io.Discard, nil)), service, "sheet-id", "test")
+	c.limiter.interval = 0
+	return c
+}
+
+func testPosts(n int) []post.Post {
+	posts := make([]post.Post, n)
+	for i := range posts {
+		posts[i] = post.Post{ID: string(rune('a' + i)), URI: "https://example.com", Content: "Wordle 1,236 4/6", Source: post.Mastodon, Type: post.Wordle}
+	}
+	return posts
+}
+
+func TestWriteBatch(t *testing.T) {
+	fake := &fakeSheets{}
+	c := newTestClient(t, fake)
+	c.BatchSize = 2
+
+	if err := c.WriteBatch(context.Background(), testPosts(5)); err != nil {
+		t.Fatalf("WriteBatch() error = %v", err)
+	}
+	if got, want := fake.batches(), []int{2, 2, 1}; !reflect.DeepEqual(got, want) {
+		t.Errorf("append sizes = %v, want %v", got, want)
+	}
+}
+
+func TestWritePerType(t *testing.T) {
+	fake := &fakeSheets{
+		tabs:    map[string]bool{"test": true, "wordle": true},
+		headers: map[string][]interface{}{},
+	}
+	c := newTestClient(t, fake)
+	c.PerType = true
+
+	posts := testPosts(3)
+	posts[1].Type = post.Strands
+	posts[2].Type = ""
+
+	if err := c.WriteBatch(context.Background(), posts); err != nil {
+		t.Fatalf("WriteBatch() error = %v", err)
+	}
+
+	if got, want := fake.appendTabs, []string{"wordle", "strands", "test"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("appended to tabs %v, want %v", got, want)
+	}
+	if !fake.tabs["strands"] {
+		t.Error("strands tab was not created")
+	}
+	header := []interface{}{"ID", "URI", "Type", "Content", "Source", "Responded"}
+	if got := fake.headers["strands"]; !reflect.DeepEqual(got, header) {
+		t.Errorf("strands header = %v, want %v", got, header)
+	}
+	if _, ok := fake.headers["wordle"]; ok {
+		t.Error("header was rewritten on the existing wordle tab")
+	}
+}
+
+func TestWritePerTypePartialFailure(t *testing.T) {
+	fake := &fakeSheets{
+		tabs:    map[string]bool{"test": true, "wordle": true, "strands": true},
+		headers: map[string][]interface{}{},
+		failTab: "strands",
+	}
+	c := newTestClient(t, fake)
+	c.PerType = true
+
+	posts := testPosts(3)
+	posts[1].Type = post.Strands
+
+	err := c.WriteBatch(context.Background(), posts)
+	var partial *spreadsheet.BatchError
+	if !errors.As(err, &partial) {
+		t.Fatalf("WriteBatch() error = %v, want a *spreadsheet.BatchError", err)
+	}
+	if want := []int{0, 2}; !reflect.DeepEqual(partial.Written, want) {
+		t.Errorf("Written = %v, want %v", partial.Written, want)
+	}
+}
+
+func TestWritePerTypeRetriesHeader(t *testing.T) {
+	fake := &fakeSheets{
+		tabs:        map[string]bool{"test": true},
+		headers:     map[string][]interface{}{},
+		failHeaders: 1,
+	}
+	c := newTestClient(t, fake)
+	c.PerType = true
+
+	posts := testPosts(1)
+	if err := c.WriteBatch(context.Background(), posts); err == nil {
+		t.Fatal("WriteBatch() error = nil, want the header write to fail")
+	}
+	if err := c.WriteBatch(context.Background(), posts); err != nil {
+		t.Fatalf("WriteBatch() error = %v", err)
+	}
+
+	if fake.addSheets != 1 {
+		t.Errorf("wordle tab was added %d times, want once", fake.addSheets)
+	}
+	if _, ok := fake.headers["wordle"]; !ok {
+		t.Error("header was not written to the wordle tab")
+	}
+	if got, want := fake.appendTabs, []string{"wordle"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("appended to tabs %v, want %v", got, want)
+	}
+}
+
+func TestWritePerTypeTabCreatedElsewhere(t *testing.T) {
+	fake := &fakeSheets{
+		tabs:    map[string]bool{"test": true},
+		headers: map[string][]interface{}{},
+	}
+	c := newTestClient(t, fake)
+	c.PerType = true
+	c.tabs = map[string]bool{"test": true}
+	fake.tabs["wordle"] = true
+
+	if err := c.WriteBatch(context.Background(), testPosts(1)); err != nil {
+		t.Fatalf("WriteBatch() error = %v", err)
+	}
+	if _, ok := fake.headers["wordle"]; ok {
+		t.Error("header was written to a tab that already existed")
+	}
+}
+
+func TestWriteTabWithSpaces(t *testing.T) {
+	fake := &fakeSheets{}
+	c := newTestClient(t, fake)
+	c.SheetName = "NYT posts - Bob's"
+
+	if err := c.WriteBatch(context.Background(), testPosts(1)); err != nil {
+		t.Fatalf("WriteBatch() error = %v", err)
+	}
+	if got, want := fake.appendTabs, []string{"NYT posts - Bob's"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("appended to tabs %v, want %v", got, want)
+	}
+}
+
+func TestWriteCoalesces(t *testing.T) {
+	fake := &fakeSheets{}
+	c := newTestClient(t, fake)
+	c.BatchSize = 3
+	c.FlushInterval = 50 * time.Millisecond
+
+	// a full batch is appended straight away
+	var wg sync.WaitGroup
+	for _, p := range testPosts(3) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := c.Write(context.Background(), p); err != nil {
+				t.Errorf("Write() error = %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	// a partial batch is appended once the flush interval passes
+	if err := c.Write(context.Background(), testPosts(1)[0]); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+
+	if err := c.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if err := c.Write(context.Background(), testPosts(1)[0]); err != errClosed {
+		t.Errorf("Write() after Close() error = %v, want %v", err, errClosed)
+	}
+
+	if got, want := fake.batches(), []int{3, 1}; !reflect.DeepEqual(got, want) {
+		t.Errorf("append sizes = %v, want %v", got, want)
+	}
+}
+
+func TestWriteRetriesQuotaErrors(t *testing.T) {
+	fake := &fakeSheets{throttle: 2}
+	c := newTestClient(t, fake)
+
+	if err := c.Write(context.Background(), testPosts(1)[0]); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	if got, want := fake.batches(), []int{1}; !reflect.DeepEqual(got, want) {
+		t.Errorf("append sizes = %v, want %v", got, want)
+	}
+}
+
+func TestCloseGivesUpOnFlush(t *testing.T) {
+	// quota errors asking to come back in an hour
+	fake := &fakeSheets{throttle: 1, retryAfter: "3600"}
+	c := newTestClient(t, fake)
+	c.BatchSize = 2
+	c.FlushInterval = time.Hour
+	c.WriteTimeout = 50 * time.Millisecond
+
+	errs := make(chan error, 1)
+	go func() {
+		errs <- c.Write(context.Background(), testPosts(1)[0])
+	}()
+	// let the row reach the batcher before closing
+	time.Sleep(10 * time.Millisecond)
+
+	closed := make(chan struct{})
+	go func() {
+		c.Close()
+		close(closed)
+	}()
+
+	select {
+	case <-closed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Close() did not give up on the flush")
+	}
+	if err := <-errs; err == nil {
+		t.Error("Write() of a row that was never appended should fail")
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	now := time.Date(2024, 11, 6, 9, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name  string
+		value string
+		want  time.Duration
+	}{
+		{"missing", "", 5 * time.Second},
+		{"seconds", "30", 30 * time.Second},
+		{"http date", now.Add(time.Minute).Format(http.TimeFormat), time.Minute},
+		{"date in the past", now.Add(-time.Minute).Format(http.TimeFormat), 0},
+		{"invalid", "soon", 5 * time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := http.Header{}
+			if tt.value != "" {
+				h.Set("Retry-After", tt.value)
+			}
+			if got := retryAfter(h, now, 5*time.Second); got != tt.want {
+				t.Errorf("retryAfter() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRateLimiter(t *testing.T) {
+	now := time.Date(2024, 11, 6, 9, 0, 0, 0, time.UTC)
+	r := &rateLimiter{interval: time.Second, now: func() time.Time { return now }}
+
+	// the first call goes straight through and books the next slot
+	if err := r.wait(context.Background()); err != nil {
+		t.Fatalf("wait() error = %v", err)
+	}
+	if want := now.Add(time.Second); !r.next.Equal(want) {
+		t.Errorf("next = %v, want %v", r.next, want)
+	}
+
+	r.pause(time.Minute)
+	if want := now.Add(time.Minute); !r.next.Equal(want) {
+		t.Errorf("next after pause = %v, want %v", r.next, want)
+	}
+
+	// a caller that cannot wait for its slot gives up with the context
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := r.wait(ctx); err != context.Canceled {
+		t.Errorf("wait() error = %v, want %v", err, context.Canceled)
+	}
+}
@@ -0,0 +1,71 @@
+package gsheets
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// rateLimiter spaces out calls to the Sheets API so the bot stays under the
+// per-minute write quota, and holds every caller back after a 429 until the
+// server says it is fine to try again.
+type rateLimiter struct {
+	mu       sync.Mutex
+	interval time.Duration
+	next     time.Time
+	now      func() time.Time
+}
+
+// wait blocks until the caller may make a request.
+func (r *rateLimiter) wait(ctx context.Context) error {
+	r.mu.Lock()
+	now := r.now()
+	at := r.next
+	if at.Before(now) {
+		at = now
+	}
+	r.next = at.Add(r.interval)
+	r.mu.Unlock()
+
+	if d := at.Sub(now); d > 0 {
+		t := time.NewTimer(d)
+		defer t.Stop()
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+// pause holds back every request for at least d from now.
+func (r *rateLimiter) pause(d time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if until := r.now().Add(d); until.After(r.next) {
+		r.next = until
+	}
+}
+
+// retryAfter parses a Retry-After header, which is either a number of
+// seconds or an HTTP date, falling back to def when it is missing or invalid.
+func retryAfter(h http.Header, now time.Time, def time.Duration) time.Duration {
+	v := h.Get("Retry-After")
+	if v == "" {
+		return def
+	}
+	if secs, err := strconv.Atoi(v); err == nil && secs >= 0 {
+		return time.Duration(secs) * time.Second
+	}
+	if at, err := http.ParseTime(v); err == nil {
+		if d := at.Sub(now); d > 0 {
+			return d
+		}
+		return 0
+	}
+	return def
+}
@@ -0,0 +1,181 @@
+package gsheets
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"google.golang.org/api/sheets/v4"
+
+	"github.com/togdon/reply-bot/bot/pkg/post"
+)
+
+// Row is a post read back from the sheet along with whether a volunteer
+// has ticked its Responded checkbox.
+type Row struct {
+	// Tab is the tab the row was read from and Number its 1-based row
+	// number within it.
+	Tab       string
+	Number    int
+	Post      post.Post
+	Responded bool
+}
+
+// ReadRows returns every post in the sheet, skipping header rows and any
+// blank rows. With PerType set, every content type tab is read as well as
+// SheetName.
+func (c *Client) ReadRows(ctx context.Context) ([]Row, error) {
+	tabs, err := c.knownTabs(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var rows []Row
+	for _, tab := range tabs {
+		tabRows, err := c.readTab(ctx, tab)
+		if err != nil {
+			return nil, err
+		}
+		rows = append(rows, tabRows...)
+	}
+	return rows, nil
+}
+
+func (c *Client) readTab(ctx context.Context, tab string) ([]Row, error) {
+	var resp *sheets.ValueRange
+	err := c.call(ctx, func() error {
+		var err error
+		resp, err = c.Service.Spreadsheets.Values.Get(c.SheetID, c.columnRange(tab)).
+			ValueRenderOption("UNFORMATTED_VALUE").Context(ctx).Do()
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to read rows from tab %q: %w", tab, err)
+	}
+
+	rows, err := parseRows(c.logger.With("tab", tab), resp.Values, c.Columns)
+	if err != nil {
+		return nil, fmt.Errorf("tab %q: %w", tab, err)
+	}
+	for i := range rows {
+		rows[i].Tab = tab
+	}
+	return rows, nil
+}
+
+// Pending returns the posts nobody has responded to yet.
+func (c *Client) Pending(ctx context.Context) ([]Row, error) {
+	return c.filterRows(ctx, false)
+}
+
+// Responded returns the posts that have been responded to.
+func (c *Client) Responded(ctx context.Context) ([]Row, error) {
+	return c.filterRows(ctx, true)
+}
+
+func (c *Client) filterRows(ctx context.Context, responded bool) ([]Row, error) {
+	rows, err := c.ReadRows(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var filtered []Row
+	for _, r := range rows {
+		if r.Responded == responded {
+			filtered = append(filtered, r)
+		}
+	}
+	return filtered, nil
+}
+
+// parseRows converts sheet values laid out according to columns back into
+// posts. A first row with the ID column's header, or whose Responded cell is
+// not a checkbox value, is taken to be the header. Any other Responded cell
+// that is not a checkbox value is logged and taken as not responded, so the
+// post stays pending, and a row with a cell that cannot be parsed is logged
+// and skipped, so one bad edit does not hide the rest of the sheet.
+func parseRows(logger *slog.Logger, values [][]interface{}, columns []Column) ([]Row, error) {
+	idCol, respondedCol := -1, -1
+	for i, col := range columns {
+		switch col.Field {
+		case FieldID:
+			idCol = i
+		case FieldResponded:
+			respondedCol = i
+		}
+	}
+	if idCol < 0 {
+		return nil, fmt.Errorf("column layout has no %q field to read rows by", FieldID)
+	}
+
+	var rows []Row
+	for i, v := range values {
+		id := cell(v, idCol)
+		if id == "" || (i == 0 && strings.EqualFold(id, columns[idCol].Header)) {
+			continue
+		}
+
+		var responded bool
+		if respondedCol >= 0 {
+			var err error
+			responded, err = parseBool(cell(v, respondedCol))
+			if err != nil {
+				if i == 0 {
+					continue
+				}
+				logger.Warn("Invalid Responded value, treating the row as not responded", "row", i+1, "err", err)
+			}
+		}
+
+		p, err := parsePost(v, columns)
+		if err != nil {
+			logger.Warn("Skipping row that cannot be read", "row", i+1, "err", err)
+			continue
+		}
+
+		rows = append(rows, Row{
+			Number:    i + 1,
+			Post:      p,
+			Responded: responded,
+		})
+	}
+	return rows, nil
+}
+
+// parsePost reads the post fields of a single row.
+func parsePost(row []interface{}, columns []Column) (post.Post, error) {
+	var p post.Post
+	for i, col := range columns {
+		parse := fields[col.Field].parse
+		if parse == nil {
+			continue
+		}
+		if err := parse(&p, cell(row, i)); err != nil {
+			return post.Post{}, fmt.Errorf("invalid %s value: %w", col.Header, err)
+		}
+	}
+	return p, nil
+}
+
+// cell returns the i-th value of a row as a string; the API omits trailing
+// empty cells so the row may be shorter than expected.
+func cell(row []interface{}, i int) string {
+	if i < 0 || i >= len(row) || row[i] == nil {
+		return ""
+	}
+	return fmt.Sprint(row[i])
+}
+
+// parseBool understands both the unformatted value of a checkbox and the
+// text a volunteer might type into the column instead. An empty cell is an
+// unticked checkbox.
+func parseBool(s string) (bool, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "true", "yes", "y", "1", "x", "✓", "✔":
+		return true, nil
+	case "false", "no", "n", "0", "":
+		return false, nil
+	}
+	return false, fmt.Errorf("%q is not a checkbox value", s)
+}
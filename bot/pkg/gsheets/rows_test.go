@@ -0,0 +1,155 @@
+package gsheets
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"reflect"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/togdon/reply-bot/bot/pkg/post"
+)
+
+var discard = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+func TestParseRows(t *testing.T) {
+	wordle := post.Post{ID: "1", URI: "https://mastodon.social/@a/1", Type: post.Wordle, Content: "Wordle 1,236 4/6", Source: post.Mastodon}
+	strands := post.Post{ID: "2", URI: "https://bsky.app/profile/a/post/2", Type: post.Strands, Content: "Strands #248", Source: post.BlueSky}
+
+	tests := []struct {
+		name    string
+		values  [][]interface{}
+		want    []Row
+		wantErr bool
+	}{
+		{
+			name: "header, checkboxes and blank rows",
+			values: [][]interface{}{
+				{"ID", "URI", "Type", "Content", "Source", "Responded"},
+				{"1", "https://mastodon.social/@a/1", "wordle", "Wordle 1,236 4/6", "mastodon", false},
+				{},
+				{"2", "https://bsky.app/profile/a/post/2", "strands", "Strands #248", "bluesky", true},
+			},
+			want: []Row{
+				{Number: 2, Post: wordle, Responded: false},
+				{Number: 4, Post: strands, Responded: true},
+			},
+		},
+		{
+			name: "typed values and missing trailing cells",
+			values: [][]interface{}{
+				{"1", "https://mastodon.social/@a/1", "wordle", "Wordle 1,236 4/6", "mastodon"},
+				{"2", "https://bsky.app/profile/a/post/2", "strands", "Strands #248", "bluesky", "TRUE"},
+			},
+			want: []Row{
+				{Number: 1, Post: wordle, Responded: false},
+				{Number: 2, Post: strands, Responded: true},
+			},
+		},
+		{
+			name: "invalid responded value is not responded",
+			values: [][]interface{}{
+				{"1", "https://mastodon.social/@a/1", "wordle", "Wordle 1,236 4/6", "mastodon", false},
+				{"2", "https://bsky.app/profile/a/post/2", "strands", "Strands #248", "bluesky", "maybe"},
+			},
+			want: []Row{
+				{Number: 1, Post: wordle, Responded: false},
+				{Number: 2, Post: strands, Responded: false},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseRows(discard, tt.values, DefaultColumns)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseRows() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseRows() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseRowsSkipsUnreadableRows(t *testing.T) {
+	columns := append(slices.Clone(DefaultColumns), Column{Header: "Detected At", Field: FieldDetectedAt})
+	values := [][]interface{}{
+		{"1", "https://mastodon.social/@a/1", "wordle", "Wordle 1,236 4/6", "mastodon", false, "2024-11-02 10:00:00"},
+		{"2", "https://bsky.app/profile/a/post/2", "strands", "Strands #248", "bluesky", true, "last tuesday"},
+	}
+
+	rows, err := parseRows(discard, values, columns)
+	if err != nil {
+		t.Fatalf("parseRows() error = %v", err)
+	}
+	if len(rows) != 1 || rows[0].Post.ID != "1" {
+		t.Errorf("parseRows() = %+v, want only row 1", rows)
+	}
+}
+
+func TestParseRowsDetectedAtSerial(t *testing.T) {
+	columns := append(slices.Clone(DefaultColumns), Column{Header: "Detected At", Field: FieldDetectedAt})
+	// as the API returns a date with UNFORMATTED_VALUE
+	values := [][]interface{}{
+		{"1", "https://mastodon.social/@a/1", "wordle", "Wordle 1,236 4/6", "mastodon", false, 45602.395833333336},
+	}
+
+	rows, err := parseRows(discard, values, columns)
+	if err != nil {
+		t.Fatalf("parseRows() error = %v", err)
+	}
+	want := time.Date(2024, 11, 6, 9, 30, 0, 0, time.UTC)
+	if len(rows) != 1 || !rows[0].Post.DetectedAt.Equal(want) {
+		t.Errorf("parseRows() = %+v, want row 1 detected at %v", rows, want)
+	}
+}
+
+func TestParseRowsWithoutID(t *testing.T) {
+	columns := []Column{{Header: "URI", Field: FieldURI}, {Header: "Responded", Field: FieldResponded}}
+	if _, err := parseRows(discard, [][]interface{}{{"https://example.com", false}}, columns); err == nil {
+		t.Error("parseRows() error = nil, want an error for a layout without an id column")
+	}
+}
+
+func TestPending(t *testing.T) {
+	fake := &fakeSheets{values: [][]interface{}{
+		{"ID", "URI", "Type", "Content", "Source", "Responded"},
+		{"1", "https://mastodon.social/@a/1", "wordle", "Wordle 1,236 4/6", "mastodon", false},
+		{"2", "https://bsky.app/profile/a/post/2", "strands", "Strands #248", "bluesky", true},
+	}}
+	c := newTestClient(t, fake)
+
+	pending, err := c.Pending(context.Background())
+	if err != nil {
+		t.Fatalf("Pending() error = %v", err)
+	}
+	if len(pending) != 1 || pending[0].Post.ID != "1" {
+		t.Errorf("Pending() = %+v, want only post 1", pending)
+	}
+
+	responded, err := c.Responded(context.Background())
+	if err != nil {
+		t.Fatalf("Responded() error = %v", err)
+	}
+	if len(responded) != 1 || responded[0].Post.ID != "2" {
+		t.Errorf("Responded() = %+v, want only post 2", responded)
+	}
+}
+
+func TestPendingRetriesQuotaErrors(t *testing.T) {
+	fake := &fakeSheets{throttle: 2, values: [][]interface{}{
+		{"1", "https://mastodon.social/@a/1", "wordle", "Wordle 1,236 4/6", "mastodon", false},
+	}}
+	c := newTestClient(t, fake)
+
+	pending, err := c.Pending(context.Background())
+	if err != nil {
+		t.Fatalf("Pending() error = %v", err)
+	}
+	if len(pending) != 1 {
+		t.Errorf("Pending() = %+v, want post 1", pending)
+	}
+}
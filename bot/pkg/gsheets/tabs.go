@@ -0,0 +1,126 @@
+package gsheets
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/sheets/v4"
+
+	"github.com/togdon/reply-bot/bot/pkg/post"
+)
+
+// tabFor returns the name of the tab a post is written to.
+func (c *Client) tabFor(p post.Post) string {
+	if c.PerType && p.Type != "" {
+		return string(p.Type)
+	}
+	return c.SheetName
+}
+
+// ensureTab creates tab with a header row unless it already exists. The tab
+// is recorded as soon as it has been created, so if writing the header
+// fails only the header is retried next time.
+func (c *Client) ensureTab(ctx context.Context, tab string) error {
+	c.tabsMu.Lock()
+	defer c.tabsMu.Unlock()
+
+	if c.tabs == nil {
+		tabs, err := c.listTabs(ctx)
+		if err != nil {
+			return err
+		}
+		c.tabs = tabs
+	}
+
+	hasHeader, exists := c.tabs[tab]
+	if !exists {
+		created, err := c.addTab(ctx, tab)
+		if err != nil {
+			return err
+		}
+		// a tab someone else created is left as it is, like the tabs
+		// that already existed at startup
+		c.tabs[tab] = !created
+		hasHeader = !created
+		if created {
+			c.logger.Info("Created tab", "tab", tab)
+		}
+	}
+	if hasHeader {
+		return nil
+	}
+
+	if err := c.writeHeader(ctx, tab); err != nil {
+		return err
+	}
+	c.tabs[tab] = true
+	return nil
+}
+
+// addTab adds tab to the spreadsheet, returning false if a tab with that
+// name already exists.
+func (c *Client) addTab(ctx context.Context, tab string) (bool, error) {
+	err := c.call(ctx, func() error {
+		_, err := c.Service.Spreadsheets.BatchUpdate(c.SheetID, &sheets.BatchUpdateSpreadsheetRequest{
+			Requests: []*sheets.Request{{
+				AddSheet: &sheets.AddSheetRequest{
+					Properties: &sheets.SheetProperties{Title: tab},
+				},
+			}},
+		}).Context(ctx).Do()
+		return err
+	})
+	var apiErr *googleapi.Error
+	if errors.As(err, &apiErr) && apiErr.Code == http.StatusBadRequest && strings.Contains(apiErr.Message, "already exists") {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("unable to create tab %q: %w", tab, err)
+	}
+	return true, nil
+}
+
+// listTabs returns the titles of every tab in the spreadsheet.
+func (c *Client) listTabs(ctx context.Context) (map[string]bool, error) {
+	var resp *sheets.Spreadsheet
+	err := c.call(ctx, func() error {
+		var err error
+		resp, err = c.Service.Spreadsheets.Get(c.SheetID).Fields("sheets.properties.title").Context(ctx).Do()
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to list tabs: %w", err)
+	}
+
+	tabs := make(map[string]bool, len(resp.Sheets))
+	for _, s := range resp.Sheets {
+		if s.Properties != nil {
+			tabs[s.Properties.Title] = true
+		}
+	}
+	return tabs, nil
+}
+
+// knownTabs returns SheetName and, with PerType set, every content type tab
+// that already exists.
+func (c *Client) knownTabs(ctx context.Context) ([]string, error) {
+	tabs := []string{c.SheetName}
+	if !c.PerType {
+		return tabs, nil
+	}
+
+	existing, err := c.listTabs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range post.ContentTypes() {
+		if tab := string(t); tab != c.SheetName && existing[tab] {
+			tabs = append(tabs, tab)
+		}
+	}
+	return tabs, nil
+}
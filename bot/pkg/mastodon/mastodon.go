@@ -13,19 +13,15 @@ import (
 
 	"github.com/mattn/go-mastodon"
 	"github.com/togdon/reply-bot/bot/pkg/environment"
-	"github.com/togdon/reply-bot/bot/pkg/gsheets"
 	"github.com/togdon/reply-bot/bot/pkg/post"
+	"github.com/togdon/reply-bot/bot/pkg/spreadsheet"
 	"golang.org/x/net/html"
 )
 
-const (
-	gamesRegex = `(?P<wordle>Wordle\s[1-9],[0-9]{3}\s[X,1-6]\/[1-6])|(?P<connections>Connections\nPuzzle\s\#[1-6]{3}\n[🟨|🟩|🟦|🟪]*\n)|(?P<strands>.*Strands\s\#[1-9]{3})|(?P<crossword>I\ssolved\sthe\s[0-9]{2}\/[0-9]{2}\/[0-9]{4}\sNew\sYork\sTimes(\sMini)?\sCrossword\sin\s)`
-)
-
 type Client struct {
 	mastodonClient *mastodon.Client
 	writeChannel   chan interface{}
-	gsheetsClient  *gsheets.Client
+	sink           spreadsheet.Sink
 	logger         *slog.Logger
 }
 
@@ -48,7 +44,7 @@ func WithConfig(cfg environment.Config) Option {
 	}
 }
 
-func NewClient(logger *slog.Logger, ch chan interface{}, gsheetsClient *gsheets.Client, options ...Option) (*Client, error) {
+func NewClient(logger *slog.Logger, ch chan interface{}, sink spreadsheet.Sink, options ...Option) (*Client, error) {
 	var cfg config
 
 	for _, opt := range options {
@@ -65,9 +61,9 @@ func NewClient(logger *slog.Logger, ch chan interface{}, gsheetsClient *gsheets.
 				ClientSecret: cfg.clientSecret,
 				AccessToken:  cfg.accessToken,
 			}),
-		gsheetsClient: gsheetsClient,
-		writeChannel:  ch,
-		logger:        logger,
+		sink:         sink,
+		writeChannel: ch,
+		logger:       logger,
 	}, nil
 }
 
@@ -97,12 +93,12 @@ func (c *Client) Run(ctx context.Context, cancel context.CancelFunc, errs chan e
 			switch e := event.(type) {
 			case *mastodon.UpdateEvent:
 				c.logger.Debug("content form update", "content", e.Status.Content)
-				ok, contentType := c.getContentType(e.Status.Content)
+				ok, match := c.getContentType(e.Status.Content, e.Status.Account.Acct)
 				if ok {
 					c.logger.Info("Event content", "uri", e.Status.URI, "content", e.Status.Content)
-					post, err := createPost(e.Status.URI, e.Status.Content, contentType)
+					post, err := createPost(e.Status, match)
 					if err == nil {
-						c.writeChannel <- post
+						c.send(ctx, post)
 						continue
 					} else {
 						c.logger.Error("Unable to write post", "err", err)
@@ -113,12 +109,12 @@ func (c *Client) Run(ctx context.Context, cancel context.CancelFunc, errs chan e
 				}
 			case *mastodon.UpdateEditEvent:
 				c.logger.Debug("content from update edit event", "content", e.Status.Content)
-				ok, contentType := c.getContentType(e.Status.Content)
+				ok, match := c.getContentType(e.Status.Content, e.Status.Account.Acct)
 				if ok {
 					c.logger.Info("event content", "uri", e.Status.URI, "content", e.Status.Content)
-					post, err := createPost(e.Status.URI, e.Status.Content, contentType)
+					post, err := createPost(e.Status, match)
 					if err == nil {
-						c.writeChannel <- post
+						c.send(ctx, post)
 						continue
 					} else {
 						c.logger.Error("Unable to write post", "err", err)
@@ -152,73 +148,103 @@ func sendToStream(streamCh chan mastodon.Event, errs chan error, inCh chan masto
 	}()
 }
 
+// send hands a post over to Write, giving up if the client is shutting down
+// before Write could accept it.
+func (c *Client) send(ctx context.Context, p *post.Post) {
+	select {
+	case c.writeChannel <- p:
+	case <-ctx.Done():
+		c.logger.Warn("Shutting down, post not queued for writing", "uri", p.URI)
+	}
+}
+
+// Write sends posts from the write channel to the sink until ctx is
+// cancelled, then drains anything still buffered in the channel so it is not
+// lost on shutdown.
 func (c *Client) Write(ctx context.Context) {
 
 	for {
 		select {
 		case event := <-c.writeChannel:
-			switch e := event.(type) {
-			case *post.Post:
-				c.logger.Debug("Post received", "post", e)
-				err := c.gsheetsClient.AppendRow(*e)
-				if err != nil {
-					c.logger.Error("unable to write post to gsheet", "err", err)
-				}
-			default:
-				// How should we handle this?
-			}
+			c.write(ctx, event)
 		case <-ctx.Done():
+			c.drain(context.WithoutCancel(ctx))
 			c.logger.Info("Context cancelled, shutting down Mastodon client...")
 			return
 		}
 	}
 }
 
-func createPost(URI string, content string, postType post.NYTContentType) (*post.Post, error) {
-	if URI == "" || content == "" {
-		return nil, fmt.Errorf("empty content or uri. Content: %s, URI: %s", URI, content)
+func (c *Client) drain(ctx context.Context) {
+	for {
+		select {
+		case event := <-c.writeChannel:
+			c.write(ctx, event)
+		default:
+			return
+		}
+	}
+}
+
+func (c *Client) write(ctx context.Context, event interface{}) {
+	switch e := event.(type) {
+	case *post.Post:
+		c.logger.Debug("Post received", "post", e)
+		err := c.sink.Write(ctx, *e)
+		if err != nil {
+			c.logger.Error("unable to write post to sink", "err", err)
+		}
+	default:
+		// How should we handle this?
+	}
+}
+
+// createPost builds a post from a status, storing its content as plain text
+func createPost(status *mastodon.Status, match post.Match) (*post.Post, error) {
+	content := post.HTMLToText(status.Content)
+	if status.URI == "" || content == "" {
+		return nil, fmt.Errorf("empty content or uri. Content: %s, URI: %s", status.Content, status.URI)
 	}
 	post := post.Post{
-		ID:      URI,
-		URI:     URI,
-		Content: content,
-		Type:    postType,
-		Source:  post.Mastodon,
+		ID:         status.URI,
+		URI:        status.URI,
+		Content:    content,
+		Type:       match.Type,
+		Source:     post.Mastodon,
+		DetectedAt: time.Now().UTC(),
+		Author:     status.Account.Acct,
+		Language:   status.Language,
+		Rule:       match.Rule,
+		Game:       post.ParseGame(match.Type, content),
+		Link:       post.ParseLink(match.URL),
 	}
 	return &post, nil
 }
 
 // parses the content of a post and returns true if it contains a match for NYT Urls or Games shares
-func (c *Client) getContentType(content string) (bool, post.NYTContentType) {
-	var contentType post.NYTContentType
-	if content != "" {
-		// first, check for NYT URLs
-		if parseURLs(findURLs(content)) {
-			c.logger.Info("Found NYT Cooking URL")
-			return true, post.Cooking
-		}
-
-		// next, check for NYT Games shares
-		re := regexp.MustCompile(gamesRegex)
-		if re.MatchString(content) {
-			c.logger.Info("group name", "name", extractContentType(content, re))
-			return true, contentType
-		}
+// that isn't excluded. Detection runs on the plain text of the content, while links are taken from its HTML
+func (c *Client) getContentType(content, author string) (bool, post.Match) {
+	if content == "" {
+		return false, post.Match{}
 	}
 
-	return false, contentType
-}
-
-func extractContentType(content string, re *regexp.Regexp) post.NYTContentType {
-	groupNames := re.SubexpNames()[1:]
-	var contentType post.NYTContentType
+	candidate := post.Candidate{
+		Text:   post.HTMLToText(content),
+		URLs:   unfurlURLs(findURLs(content)),
+		Author: author,
+	}
+	match, ok := post.Detect(candidate)
+	if !ok {
+		return false, match
+	}
 
-	for _, match := range re.FindStringSubmatch(content) {
-		contentType = post.GetContentType(match, groupNames)
-		return contentType
+	if name, reason, excluded := post.Exclude(candidate, match); excluded {
+		c.logger.Info("Excluded NYT content", "type", match.Type, "rule", match.Rule, "exclusion", name, "reason", reason)
+		return false, post.Match{}
 	}
 
-	return contentType
+	c.logger.Info("Detected NYT content", "type", match.Type, "rule", match.Rule, "confidence", match.Confidence)
+	return true, match
 }
 
 // findURLs takes a string of event.Status.Content and returns a string of URLs
@@ -269,26 +295,35 @@ func findURLs(s string) string {
 	return buf.String()
 }
 
-func parseURLs(urls string) bool {
+// unfurlRE matches links on the most common URL shorteners
+var unfurlRE = regexp.MustCompile(`(?i)(aje\.io|amzn\.to|api\.follow\.it|bbc\.in|bit\.ly|buff\.ly|cnet\.co|cnn\.it|d\.pr|dlvr\.it|engt\.co|flic\.kr|goo\.gl|ift\.tt|is\.gd|j\.mp|lat\.ms|nbcnews\.to|npi\.li|nyer\.cm|nyti\.ms|on\.ft\.com|on\.msnbc\.com|on\.natgeo\.com|on\.soundcloud\.com|on\.substack\.co|on\.wsj\.com|ow\.ly|pst\.cr|\/redd\.it|reut\.rs|shar\.es|spoti\.fi|st\.news|t\.co|t\.ly|tcrn\.ch|\/ti\.me|tiny\.cc|tinyurl\.com|trib\.al|w\.wiki|wapo\.st|youtu\.be)/`)
+
+// unfurlURLs takes the newline separated output of findURLs and returns each
+// URL with the most common URL shorteners followed to their destination
+func unfurlURLs(urls string) []string {
+	var unfurled []string
 	if urls != "" {
 		for _, u := range strings.Split(strings.TrimSuffix(urls, "\n"), "\n") {
 			// A loop to unfurl the most common URL shorteners; several of these
 			// (e.g., xyz -> trib.al -> real url) are used more than once, or have
-			// both an http and https link, we loop until they're unfurled
-			unfurlRE := regexp.MustCompile(`(?i)(aje\.io|amzn\.to|api\.follow\.it|bbc\.in|bit\.ly|buff\.ly|cnet\.co|cnn\.it|d\.pr|dlvr\.it|engt\.co|flic\.kr|goo\.gl|ift\.tt|is\.gd|j\.mp|lat\.ms|nbcnews\.to|npi\.li|nyer\.cm|nyti\.ms|on\.ft\.com|on\.msnbc\.com|on\.natgeo\.com|on\.soundcloud\.com|on\.substack\.co|on\.wsj\.com|ow\.ly|pst\.cr|\/redd\.it|reut\.rs|shar\.es|spoti\.fi|st\.news|t\.co|t\.ly|tcrn\.ch|\/ti\.me|tiny\.cc|tinyurl\.com|trib\.al|w\.wiki|wapo\.st|youtu\.be)/`)
-
+			// both an http and https link, we loop until they're unfurled.
+			// If a hop can't be followed, keep the last link we had so
+			// that e.g. an nyti.ms link can still be classified
 			for i := 0; unfurlRE.MatchString(u) && i < 4; i++ {
-				u = unfurlURL(u)
+				next := unfurlURL(u)
+				if next == "" {
+					break
+				}
+				u = next
 			}
 
-			newsRE := regexp.MustCompile(`(?i)cooking\.nytimes\.com`)
-			if newsRE.MatchString(u) {
-				return true
+			if u != "" {
+				unfurled = append(unfurled, u)
 			}
 		}
 	}
 
-	return false
+	return unfurled
 }
 
 // unfurlURL takes a URL and returns the final URL after following any redirects
@@ -1,13 +1,42 @@
 package mastodon
 
 import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"os"
 	"reflect"
-	"regexp"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/mattn/go-mastodon"
 	"github.com/togdon/reply-bot/bot/pkg/post"
 )
 
+// fakeSink records every post written to it so tests can run without a
+// Google credential.
+type fakeSink struct {
+	mu    sync.Mutex
+	posts []post.Post
+}
+
+func (f *fakeSink) Write(_ context.Context, p post.Post) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.posts = append(f.posts, p)
+	return nil
+}
+
+func (f *fakeSink) Close() error { return nil }
+
+func (f *fakeSink) written() []post.Post {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]post.Post(nil), f.posts...)
+}
+
 func TestFindURLs(t *testing.T) {
 	type args struct {
 		s string
@@ -29,20 +58,24 @@ func TestFindURLs(t *testing.T) {
 	}
 }
 
-func TestParseURLs(t *testing.T) {
+func TestUnfurlURLs(t *testing.T) {
 	type args struct {
 		urls string
 	}
 	tests := []struct {
 		name string
 		args args
+		want []string
 	}{
-		// TODO: Add test cases.
+		{"no urls", args{""}, nil},
+		{"DON'T unfurl cooking", args{"https://cooking.nytimes.com/recipes/1025063\nhttps://example.com/\n"}, []string{"https://cooking.nytimes.com/recipes/1025063", "https://example.com/"}},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			parseURLs(tt.args.urls)
+			if got := unfurlURLs(tt.args.urls); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("unfurlURLs() = %v, want %v", got, tt.want)
+			}
 		})
 	}
 }
@@ -97,7 +130,7 @@ func TestUnfurlURL(t *testing.T) {
 }
 
 func Test_getContentType(t *testing.T) {
-	re := regexp.MustCompile(gamesRegex)
+	c := &Client{logger: slog.New(slog.NewTextHandler(io.Discard, nil))}
 	type args struct {
 		content string
 	}
@@ -111,17 +144,132 @@ func Test_getContentType(t *testing.T) {
 			args: args{content: "<p>Wordle 1,236 4/6</p><p>⬜🟧⬜⬜⬜<br />⬜🟧⬜⬜⬜<br />🟧🟧🟧🟧⬜<br />🟧🟧🟧🟧🟧</p>"},
 			want: post.Wordle,
 		},
+		{
+			name: "connections present",
+			args: args{content: "<p>Connections<br />Puzzle #523<br />🟨🟨🟨🟨<br />🟩🟩🟩🟩<br />🟦🟦🟦🟦<br />🟪🟪🟪🟪</p>"},
+			want: post.Connections,
+		},
 		{
 			name: "strands present",
 			args: args{content: "#Strands #248 “Strumming right along ...”🟡🔵🔵🔵🔵🔵🔵🔵"},
 			want: post.Strands,
 		},
+		{
+			name: "cooking link present",
+			args: args{content: `<p>Making this tonight <a href="https://cooking.nytimes.com/recipes/1025063-marry-me-chicken" rel="nofollow noopener" target="_blank">cooking.nytimes.com/recipes/1025</a></p>`},
+			want: post.Cooking,
+		},
+		{
+			name: "nothing present",
+			args: args{content: `<p>Just a regular post about <a href="https://mastodon.social/tags/gardening" class="mention hashtag" rel="tag">#<span>gardening</span></a></p>`},
+			want: "",
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			if got := extractContentType(tt.args.content, re); !reflect.DeepEqual(got, tt.want) {
-				t.Errorf("getContentType() = %v, want %v", got, tt.want)
+			if _, got := c.getContentType(tt.args.content, ""); !reflect.DeepEqual(got.Type, tt.want) {
+				t.Errorf("getContentType() = %v, want %v", got.Type, tt.want)
 			}
 		})
 	}
 }
+
+func Test_getContentTypeExcluded(t *testing.T) {
+	c := &Client{logger: slog.New(slog.NewTextHandler(io.Discard, nil))}
+	registry := post.DefaultRegistry()
+	registry.SetExclusions(post.Exclusion{Name: "bots", Authors: []string{"wordlebot@example.social"}})
+	t.Cleanup(func() { registry.SetExclusions() })
+
+	content := "<p>Wordle 1,236 4/6</p><p>🟩🟩🟩🟩🟩</p>"
+	if ok, _ := c.getContentType(content, "WordleBot@example.social"); ok {
+		t.Error("getContentType() should exclude posts by an excluded author")
+	}
+	if ok, _ := c.getContentType(content, "someone@example.social"); !ok {
+		t.Error("getContentType() should detect posts by other authors")
+	}
+}
+
+func Test_createPost(t *testing.T) {
+	status := &mastodon.Status{
+		URI:      "https://mastodon.social/users/someone/statuses/1",
+		Content:  "<p>Wordle 1,236 4/6*</p><p>⬜🟧⬜⬜⬜<br />🟧🟧🟧🟧🟧</p>",
+		Account:  mastodon.Account{Acct: "someone@mastodon.social"},
+		Language: "en",
+	}
+
+	got, err := createPost(status, post.Match{Type: post.Wordle, Rule: "wordle-share"})
+	if err != nil {
+		t.Fatalf("createPost() error = %v", err)
+	}
+	if want := "Wordle 1,236 4/6*\n\n⬜🟧⬜⬜⬜\n🟧🟧🟧🟧🟧"; got.Content != want {
+		t.Errorf("Content = %q, want %q", got.Content, want)
+	}
+	if got.Game == nil || got.Game.Puzzle != 1236 || !got.Game.HardMode {
+		t.Errorf("Game = %+v, want puzzle 1236 in hard mode", got.Game)
+	}
+}
+
+func TestWrite(t *testing.T) {
+	sink := &fakeSink{}
+	ch := make(chan interface{})
+	c, err := NewClient(slog.New(slog.NewTextHandler(io.Discard, nil)), ch, sink)
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		c.Write(ctx)
+		close(done)
+	}()
+
+	want, err := createPost(&mastodon.Status{
+		URI:     "https://mastodon.social/@someone/1",
+		Content: "Wordle 1,236 4/6",
+		Account: mastodon.Account{Acct: "someone@mastodon.social"},
+	}, post.Match{Type: post.Wordle, Rule: "wordle-share", Confidence: 1})
+	if err != nil {
+		t.Fatalf("createPost() error = %v", err)
+	}
+	ch <- want
+	// anything that isn't a *post.Post is ignored
+	ch <- "not a post"
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Write() did not return after context cancellation")
+	}
+
+	if got := sink.written(); !reflect.DeepEqual(got, []post.Post{*want}) {
+		t.Errorf("sink received %v, want %v", got, []post.Post{*want})
+	}
+}
+
+// BenchmarkGetContentType measures the per-status cost of detection on the
+// streaming hot path over the post package's status corpus, which has no
+// shortened links so nothing is fetched.
+func BenchmarkGetContentType(b *testing.B) {
+	raw, err := os.ReadFile("../post/testdata/statuses.json")
+	if err != nil {
+		b.Fatal(err)
+	}
+	var statuses []struct {
+		Content string `json:"content"`
+	}
+	if err := json.Unmarshal(raw, &statuses); err != nil {
+		b.Fatal(err)
+	}
+
+	c := &Client{logger: slog.New(slog.NewTextHandler(io.Discard, nil))}
+	b.ResetTimer()
+	start := time.Now()
+	for i := 0; i < b.N; i++ {
+		for _, s := range statuses {
+			c.getContentType(s.Content, "")
+		}
+	}
+	b.ReportMetric(float64(time.Since(start).Nanoseconds())/float64(b.N*len(statuses)), "ns/status")
+}
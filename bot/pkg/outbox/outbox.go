@@ -0,0 +1,308 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/togdon/reply-bot/bot/pkg/post"
+	"github.com/togdon/reply-bot/bot/pkg/spreadsheet"
+)
+
+const (
+	minBackoff   = time.Second
+	maxBackoff   = 5 * time.Minute
+	writeTimeout = 30 * time.Second
+
+	entryExt = ".json"
+	tmpExt   = ".tmp"
+	badExt   = ".bad"
+)
+
+var _ spreadsheet.Sink = (*Outbox)(nil)
+
+// Outbox is a durable write-ahead queue in front of a sink. Write only
+// records the post on disk; Run delivers recorded posts to the sink in
+// order, retrying with exponential backoff until the sink accepts them.
+// Anything still pending when the process stops is replayed the next time
+// Run is started on the same directory.
+//
+// When the sink is a spreadsheet.BatchSink, entries are delivered through
+// WriteBatch, up to BatchSize at a time, so they are written straight away
+// rather than waiting for the sink to fill a batch of its own.
+type Outbox struct {
+	MinBackoff   time.Duration
+	MaxBackoff   time.Duration
+	WriteTimeout time.Duration
+	BatchSize    int
+
+	dir    string
+	sink   spreadsheet.Sink
+	logger *slog.Logger
+	notify chan struct{}
+
+	mu   sync.Mutex
+	next uint64
+}
+
+// New returns an outbox storing its entries in dir (created if needed) and
+// delivering them to sink.
+func New(logger *slog.Logger, dir string, sink spreadsheet.Sink) (*Outbox, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("unable to create outbox directory: %w", err)
+	}
+
+	o := &Outbox{
+		MinBackoff:   minBackoff,
+		MaxBackoff:   maxBackoff,
+		WriteTimeout: writeTimeout,
+		BatchSize:    1,
+		dir:          dir,
+		sink:         sink,
+		logger:       logger,
+		notify:       make(chan struct{}, 1),
+	}
+
+	// temporary files are left behind by a Write interrupted before it
+	// committed, so the post was never acknowledged and can be discarded
+	tmps, err := filepath.Glob(filepath.Join(dir, "*"+tmpExt))
+	if err != nil {
+		return nil, fmt.Errorf("unable to list outbox directory: %w", err)
+	}
+	for _, tmp := range tmps {
+		os.Remove(tmp)
+	}
+
+	entries, err := o.pending()
+	if err != nil {
+		return nil, err
+	}
+	if len(entries) > 0 {
+		o.next = entries[len(entries)-1] + 1
+		logger.Info("Replaying outbox entries", "dir", dir, "pending", len(entries))
+	}
+
+	return o, nil
+}
+
+// Write implements spreadsheet.Sink. Once it returns nil the post is on
+// disk and will be delivered by Run, even across restarts.
+func (o *Outbox) Write(_ context.Context, p post.Post) error {
+	data, err := json.Marshal(p)
+	if err != nil {
+		return fmt.Errorf("unable to encode outbox entry: %w", err)
+	}
+
+	o.mu.Lock()
+	seq := o.next
+	o.next++
+	o.mu.Unlock()
+
+	name := o.entryPath(seq)
+	tmp := name + tmpExt
+	if err := writeFileSync(tmp, data); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("unable to write outbox entry: %w", err)
+	}
+	if err := os.Rename(tmp, name); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("unable to commit outbox entry: %w", err)
+	}
+	if err := syncDir(o.dir); err != nil {
+		return fmt.Errorf("unable to sync outbox directory: %w", err)
+	}
+
+	select {
+	case o.notify <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// Close implements spreadsheet.Sink by closing the underlying sink. Run
+// must have returned before Close is called.
+func (o *Outbox) Close() error {
+	return o.sink.Close()
+}
+
+// Pending returns the number of entries not yet delivered.
+func (o *Outbox) Pending() (int, error) {
+	entries, err := o.pending()
+	return len(entries), err
+}
+
+// Run delivers pending entries to the sink until ctx is cancelled.
+func (o *Outbox) Run(ctx context.Context) {
+	backoff := o.MinBackoff
+
+	for {
+		entries, err := o.pending()
+		if err != nil {
+			o.logger.Error("unable to list outbox entries", "dir", o.dir, "err", err)
+		}
+
+		size := 1
+		if _, ok := o.sink.(spreadsheet.BatchSink); ok {
+			size = max(o.BatchSize, 1)
+		}
+
+		delivered := true
+		for start := 0; start < len(entries); start += size {
+			batch := entries[start:min(start+size, len(entries))]
+			if err := o.deliver(ctx, batch); err != nil {
+				o.logger.Error("unable to deliver outbox entries, retrying", "dir", o.dir, "first", batch[0], "count", len(batch), "backoff", backoff, "err", err)
+				delivered = false
+				break
+			}
+			backoff = o.MinBackoff
+		}
+
+		if delivered && err == nil {
+			select {
+			case <-o.notify:
+				continue
+			case <-ctx.Done():
+				return
+			}
+		}
+
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return
+		}
+		backoff = min(backoff*2, o.MaxBackoff)
+	}
+}
+
+// deliver writes a batch of entries to the sink and removes them once
+// accepted. Entries that cannot be decoded are set aside rather than retried
+// forever.
+func (o *Outbox) deliver(ctx context.Context, seqs []uint64) error {
+	var (
+		posts []post.Post
+		names []string
+	)
+	for _, seq := range seqs {
+		name := o.entryPath(seq)
+
+		data, err := os.ReadFile(name)
+		if err != nil {
+			return fmt.Errorf("unable to read outbox entry: %w", err)
+		}
+
+		var p post.Post
+		if err := json.Unmarshal(data, &p); err != nil {
+			o.logger.Error("discarding corrupt outbox entry", "entry", name, "err", err)
+			if err := os.Rename(name, name+badExt); err != nil {
+				return fmt.Errorf("unable to set aside corrupt outbox entry: %w", err)
+			}
+			continue
+		}
+
+		posts = append(posts, p)
+		names = append(names, name)
+	}
+
+	if len(posts) == 0 {
+		return nil
+	}
+
+	writeCtx, cancel := context.WithTimeout(ctx, o.WriteTimeout)
+	defer cancel()
+
+	var err error
+	if batch, ok := o.sink.(spreadsheet.BatchSink); ok {
+		err = batch.WriteBatch(writeCtx, posts)
+	} else {
+		err = o.sink.Write(writeCtx, posts[0])
+	}
+
+	// entries the sink stored before failing are removed so that the
+	// retry doesn't store them again
+	var partial *spreadsheet.BatchError
+	switch {
+	case err == nil:
+	case errors.As(err, &partial):
+		delivered := make([]string, 0, len(partial.Written))
+		for _, i := range partial.Written {
+			delivered = append(delivered, names[i])
+		}
+		names = delivered
+	default:
+		return err
+	}
+
+	for _, name := range names {
+		if err := os.Remove(name); err != nil {
+			return fmt.Errorf("unable to remove delivered outbox entry: %w", err)
+		}
+	}
+	return err
+}
+
+// pending returns the sequence numbers of undelivered entries in order.
+func (o *Outbox) pending() ([]uint64, error) {
+	files, err := os.ReadDir(o.dir)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read outbox directory: %w", err)
+	}
+
+	var entries []uint64
+	for _, f := range files {
+		name := f.Name()
+		if !strings.HasSuffix(name, entryExt) {
+			continue
+		}
+		seq, err := strconv.ParseUint(strings.TrimSuffix(name, entryExt), 10, 64)
+		if err != nil {
+			continue
+		}
+		entries = append(entries, seq)
+	}
+
+	sort.Slice(entries, func(i, j int) bool { return entries[i] < entries[j] })
+	return entries, nil
+}
+
+func (o *Outbox) entryPath(seq uint64) string {
+	return filepath.Join(o.dir, fmt.Sprintf("%020d%s", seq, entryExt))
+}
+
+func writeFileSync(name string, data []byte) error {
+	f, err := os.OpenFile(name, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+
+	if err := d.Sync(); err != nil && !errors.Is(err, os.ErrInvalid) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,249 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/togdon/reply-bot/bot/pkg/post"
+	"github.com/togdon/reply-bot/bot/pkg/spreadsheet"
+)
+
+// flakySink fails the first failures writes and records every post it
+// accepts.
+type flakySink struct {
+	mu       sync.Mutex
+	failures int
+	attempts int
+	posts    []post.Post
+}
+
+func (f *flakySink) Write(_ context.Context, p post.Post) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.attempts++
+	if f.attempts <= f.failures {
+		return errors.New("sheet unavailable")
+	}
+	f.posts = append(f.posts, p)
+	return nil
+}
+
+func (f *flakySink) Close() error { return nil }
+
+func (f *flakySink) written() []post.Post {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]post.Post(nil), f.posts...)
+}
+
+// batchSink records the size of every WriteBatch, and fails the test if
+// Write is used instead. With partial set, the first batch only stores its
+// odd numbered posts.
+type batchSink struct {
+	flakySink
+	t       *testing.T
+	batches []int
+	partial bool
+}
+
+func (b *batchSink) Write(context.Context, post.Post) error {
+	b.t.Error("Write() called on a BatchSink")
+	return nil
+}
+
+func (b *batchSink) WriteBatch(_ context.Context, posts []post.Post) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.batches = append(b.batches, len(posts))
+	if b.partial {
+		b.partial = false
+		var written []int
+		for i := 1; i < len(posts); i += 2 {
+			b.posts = append(b.posts, posts[i])
+			written = append(written, i)
+		}
+		return &spreadsheet.BatchError{Written: written, Err: errors.New("second tab unavailable")}
+	}
+	b.posts = append(b.posts, posts...)
+	return nil
+}
+
+func testPosts(n int) []post.Post {
+	var posts []post.Post
+	for i := 0; i < n; i++ {
+		posts = append(posts, post.Post{
+			ID:      string(rune('a' + i)),
+			URI:     "https://example.com/" + string(rune('a'+i)),
+			Content: "Wordle 1,236 4/6",
+			Source:  post.Mastodon,
+			Type:    post.Wordle,
+		})
+	}
+	return posts
+}
+
+func newTestOutbox(t *testing.T, dir string, sink *flakySink) *Outbox {
+	t.Helper()
+	o, err := New(slog.New(slog.NewTextHandler(io.Discard, nil)), dir, sink)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	o.MinBackoff = time.Millisecond
+	o.MaxBackoff = 4 * time.Millisecond
+	return o
+}
+
+func waitFor(t *testing.T, sink *flakySink, want []post.Post) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if got := sink.written(); len(got) >= len(want) {
+			if !reflect.DeepEqual(got, want) {
+				t.Fatalf("sink received %v, want %v", got, want)
+			}
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("sink received %v before timing out, want %v", sink.written(), want)
+}
+
+func TestRetry(t *testing.T) {
+	dir := t.TempDir()
+	sink := &flakySink{failures: 3}
+	o := newTestOutbox(t, dir, sink)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		o.Run(ctx)
+		close(done)
+	}()
+
+	posts := testPosts(3)
+	for _, p := range posts {
+		if err := o.Write(ctx, p); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+	}
+
+	waitFor(t, sink, posts)
+	cancel()
+	<-done
+
+	if n, err := o.Pending(); err != nil || n != 0 {
+		t.Errorf("Pending() = %d, %v, want 0 after delivery", n, err)
+	}
+}
+
+func TestBatches(t *testing.T) {
+	dir := t.TempDir()
+	posts := testPosts(5)
+
+	// entries pending at startup are delivered BatchSize at a time, and a
+	// single one is not left waiting for the sink to fill a batch
+	first := newTestOutbox(t, dir, &flakySink{})
+	for _, p := range posts[:4] {
+		if err := first.Write(context.Background(), p); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+	}
+
+	sink := &batchSink{t: t}
+	o, err := New(slog.New(slog.NewTextHandler(io.Discard, nil)), dir, sink)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	o.BatchSize = 3
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go o.Run(ctx)
+
+	waitFor(t, &sink.flakySink, posts[:4])
+	if err := o.Write(ctx, posts[4]); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	waitFor(t, &sink.flakySink, posts)
+
+	sink.mu.Lock()
+	defer sink.mu.Unlock()
+	if want := []int{3, 1, 1}; !reflect.DeepEqual(sink.batches, want) {
+		t.Errorf("batch sizes = %v, want %v", sink.batches, want)
+	}
+}
+
+func TestPartialBatch(t *testing.T) {
+	dir := t.TempDir()
+	posts := testPosts(4)
+
+	first := newTestOutbox(t, dir, &flakySink{})
+	for _, p := range posts {
+		if err := first.Write(context.Background(), p); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+	}
+
+	// only the posts the sink didn't store are retried
+	sink := &batchSink{t: t, partial: true}
+	o, err := New(slog.New(slog.NewTextHandler(io.Discard, nil)), dir, sink)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	o.BatchSize = 4
+	o.MinBackoff = time.Millisecond
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go o.Run(ctx)
+
+	waitFor(t, &sink.flakySink, []post.Post{posts[1], posts[3], posts[0], posts[2]})
+
+	sink.mu.Lock()
+	defer sink.mu.Unlock()
+	if want := []int{4, 2}; !reflect.DeepEqual(sink.batches, want) {
+		t.Errorf("batch sizes = %v, want %v", sink.batches, want)
+	}
+}
+
+func TestReplay(t *testing.T) {
+	dir := t.TempDir()
+	posts := testPosts(3)
+
+	// write without a running worker, as if the process stopped while the
+	// sink was down
+	first := newTestOutbox(t, dir, &flakySink{})
+	for _, p := range posts[:2] {
+		if err := first.Write(context.Background(), p); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+	}
+	// leftovers from an interrupted write and a corrupt entry must not be
+	// delivered
+	os.WriteFile(filepath.Join(dir, "00000000000000000099.json.tmp"), []byte("{"), 0o644)
+	os.WriteFile(filepath.Join(dir, "00000000000000000002.json"), []byte("{"), 0o644)
+
+	sink := &flakySink{}
+	second := newTestOutbox(t, dir, sink)
+	if err := second.Write(context.Background(), posts[2]); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go second.Run(ctx)
+
+	waitFor(t, sink, posts)
+
+	if _, err := os.Stat(filepath.Join(dir, "00000000000000000002.json.bad")); err != nil {
+		t.Errorf("corrupt entry was not set aside: %v", err)
+	}
+}
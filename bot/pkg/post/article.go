@@ -0,0 +1,23 @@
+package post
+
+import "regexp"
+
+// Articles are dated nytimes.com links such as
+// https://www.nytimes.com/2024/10/16/us/politics/example.html (or the
+// interactive equivalent). Links are a weaker signal than game shares, and
+// more specific products (Audio, The Athletic...) take precedence. Unresolved
+// nyti.ms short links are assumed to be articles, with lower confidence.
+func init() {
+	Register(URLDetector{
+		Type:       Article,
+		Rule:       "article-link",
+		Regex:      regexp.MustCompile(`^https://nytimes\.com/(?:interactive/)?[0-9]{4}/[0-9]{2}/[0-9]{2}/`),
+		Confidence: 0.7,
+	})
+	Register(URLDetector{
+		Type:       Article,
+		Rule:       "article-short-link",
+		Regex:      regexp.MustCompile(`^https://nyti\.ms/`),
+		Confidence: 0.5,
+	})
+}
@@ -0,0 +1,14 @@
+package post
+
+import "regexp"
+
+// The Athletic lives at nytimes.com/athletic, with theathletic.com links
+// still in circulation.
+func init() {
+	Register(URLDetector{
+		Type:       Athletic,
+		Rule:       "athletic-link",
+		Regex:      regexp.MustCompile(`^https://(?:nytimes\.com/athletic/|theathletic\.com/)`),
+		Confidence: 0.8,
+	})
+}
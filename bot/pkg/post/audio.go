@@ -0,0 +1,15 @@
+package post
+
+import "regexp"
+
+// NYT Audio covers nytimes.com/audio and podcast episodes, which are dated
+// like articles (e.g. /2024/10/16/podcasts/the-daily/...) so this has to be
+// more confident than the article detector.
+func init() {
+	Register(URLDetector{
+		Type:       Audio,
+		Rule:       "audio-link",
+		Regex:      regexp.MustCompile(`^https://nytimes\.com/(?:audio/|(?:[0-9]{4}/[0-9]{2}/[0-9]{2}/)?podcasts/)`),
+		Confidence: 0.8,
+	})
+}
@@ -0,0 +1,18 @@
+package post
+
+import "regexp"
+
+// Connections shares look like "Connections\nPuzzle #123\n" followed by a
+// grid of coloured squares.
+func init() {
+	Register(RegexDetector{
+		Type:       Connections,
+		Rule:       "connections-share",
+		Regex:      regexp.MustCompile(`Connections\s*\nPuzzle\s#(?:` + numberPattern + `)\s*\n[🟨🟩🟦🟪]*\n`),
+		Keywords:   []string{"connections"},
+		Confidence: 1,
+	})
+	RegisterParser(Connections, regexParser(
+		regexp.MustCompile(`Connections\s*\nPuzzle\s#(?P<puzzle>`+numberPattern+`)\s*\n(?P<grid>(?:[🟨🟩🟦🟪]+\n?)*)`),
+	))
+}
@@ -0,0 +1,19 @@
+package post
+
+import "regexp"
+
+// Connections: Sports Edition, from The Athletic, shares like Connections
+// but with its own title line, e.g. "Connections: Sports Edition\nPuzzle
+// #12\n" followed by the grid.
+func init() {
+	Register(RegexDetector{
+		Type:       ConnectionsSports,
+		Rule:       "connections-sports-share",
+		Regex:      regexp.MustCompile(`Connections:?\s*Sports\sEdition\s*\nPuzzle\s#(?:` + numberPattern + `)\n`),
+		Keywords:   []string{"sports"},
+		Confidence: 1,
+	})
+	RegisterParser(ConnectionsSports, regexParser(
+		regexp.MustCompile(`Sports\sEdition\s*\nPuzzle\s#(?P<puzzle>`+numberPattern+`)\s*\n(?P<grid>(?:[🟨🟩🟦🟪]+\n?)*)`),
+	))
+}
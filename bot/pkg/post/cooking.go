@@ -0,0 +1,15 @@
+package post
+
+import "regexp"
+
+// Cooking is detected from links to cooking.nytimes.com. A link alone is a
+// weaker signal than a game share, since it may be quoted rather than
+// shared.
+func init() {
+	Register(URLDetector{
+		Type:       Cooking,
+		Rule:       "cooking-link",
+		Regex:      regexp.MustCompile(`(?i)cooking\.nytimes\.com`),
+		Confidence: 0.8,
+	})
+}
@@ -0,0 +1,88 @@
+package post
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+	"time"
+)
+
+// status is an entry of testdata/statuses.json, a corpus modelled on the
+// Mastodon public timeline: mostly unrelated posts, with the HTML Mastodon
+// serves for mentions, hashtags and links, and some shares and NYT links.
+// URLs are the links a source would extract from the content, and Type the
+// content type the status should be detected as, if any.
+type status struct {
+	Content string         `json:"content"`
+	URLs    []string       `json:"urls"`
+	Type    NYTContentType `json:"type"`
+}
+
+func loadCorpus(tb testing.TB) []status {
+	tb.Helper()
+	raw, err := os.ReadFile("testdata/statuses.json")
+	if err != nil {
+		tb.Fatal(err)
+	}
+	var statuses []status
+	if err := json.Unmarshal(raw, &statuses); err != nil {
+		tb.Fatal(err)
+	}
+	return statuses
+}
+
+func TestDetectCorpus(t *testing.T) {
+	for i, s := range loadCorpus(t) {
+		got, ok := Detect(Candidate{Text: HTMLToText(s.Content), URLs: s.URLs})
+		if ok != (s.Type != "") || got.Type != s.Type {
+			t.Errorf("status %d: Detect() = %v, %v, want type %q", i, got, ok, s.Type)
+		}
+	}
+}
+
+// withoutKeywords returns the default detectors with their keyword
+// prefilters removed, to measure what the prefilter saves.
+func withoutKeywords() *Registry {
+	var detectors []Detector
+	for _, d := range DefaultRegistry().Detectors() {
+		if rd, ok := d.(RegexDetector); ok {
+			rd.Keywords = nil
+			d = rd
+		}
+		detectors = append(detectors, d)
+	}
+	return NewRegistry(detectors...)
+}
+
+// benchmarkStatuses runs detect over every status in the corpus per
+// iteration and reports the cost per status.
+func benchmarkStatuses(b *testing.B, detect func(status)) {
+	statuses := loadCorpus(b)
+	b.ResetTimer()
+	start := time.Now()
+	for i := 0; i < b.N; i++ {
+		for _, s := range statuses {
+			detect(s)
+		}
+	}
+	b.ReportMetric(float64(time.Since(start).Nanoseconds())/float64(b.N*len(statuses)), "ns/status")
+}
+
+func BenchmarkDetect(b *testing.B) {
+	benchmarkStatuses(b, func(s status) {
+		Detect(Candidate{Text: HTMLToText(s.Content), URLs: s.URLs})
+	})
+}
+
+func BenchmarkDetectWithoutKeywords(b *testing.B) {
+	r := withoutKeywords()
+	benchmarkStatuses(b, func(s status) {
+		r.Detect(Candidate{Text: HTMLToText(s.Content), URLs: s.URLs})
+	})
+}
+
+func BenchmarkHTMLToText(b *testing.B) {
+	benchmarkStatuses(b, func(s status) {
+		HTMLToText(s.Content)
+	})
+}
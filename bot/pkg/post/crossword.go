@@ -0,0 +1,23 @@
+package post
+
+import "regexp"
+
+// Crossword shares look like "I solved the Wednesday 10/16/2024 New York
+// Times Daily Crossword in 8:12!", and Mini shares like "I solved the
+// 10/16/2024 New York Times Mini Crossword in 0:51!". The named groups tell
+// the two apart.
+func init() {
+	Register(RegexDetector{
+		Type:       Crossword,
+		Rule:       "crossword-share",
+		Regex:      regexp.MustCompile(`I\ssolved\sthe\s(?:[A-Z][a-z]+day\s)?[0-9]{1,2}\/[0-9]{1,2}\/[0-9]{4}\sNew\sYork\sTimes\s(?:(?P<mini>Mini\sCrossword)|(?P<crossword>(?:Daily\s)?Crossword))\sin\s`),
+		Keywords:   []string{"crossword"},
+		Confidence: 1,
+	})
+
+	crossword := regexParser(
+		regexp.MustCompile(`(?P<date>` + datePattern + `)\sNew\sYork\sTimes\s(?:Mini\s|Daily\s)?Crossword\sin\s(?P<time>` + durationPattern + `)`),
+	)
+	RegisterParser(Crossword, crossword)
+	RegisterParser(Mini, crossword)
+}
@@ -0,0 +1,263 @@
+package post
+
+import (
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// Candidate is what a source hands to detection: the text of a post, the
+// links it contains, already unfurled, and the handle of its author.
+type Candidate struct {
+	Text string
+	URLs []string
+	// Author is the author's handle and AuthorID a permanent identifier for
+	// them, where the source has one, such as a Bluesky DID. Exclusions
+	// match either.
+	Author   string
+	AuthorID string
+
+	// lower and canonical cache the lowercased text and canonical links
+	// (see CanonicalURL), so that Registry.Detect works them out once per
+	// candidate rather than once per detector.
+	lower     *string
+	canonical []canonicalURL
+}
+
+type canonicalURL struct {
+	raw, canonical string
+}
+
+// prepare fills in the caches of c.
+func (c *Candidate) prepare() {
+	if c.lower == nil {
+		lower := strings.ToLower(c.Text)
+		c.lower = &lower
+	}
+	if c.canonical == nil && len(c.URLs) > 0 {
+		c.canonical = make([]canonicalURL, 0, len(c.URLs))
+		for _, raw := range c.URLs {
+			if u, ok := CanonicalURL(raw); ok {
+				c.canonical = append(c.canonical, canonicalURL{raw: raw, canonical: u})
+			}
+		}
+	}
+}
+
+// containsAny reports whether the text of c contains any of keywords,
+// which must be lowercase. With no keywords it is always true.
+func (c Candidate) containsAny(keywords []string) bool {
+	if len(keywords) == 0 {
+		return true
+	}
+	c.prepare()
+	for _, k := range keywords {
+		if strings.Contains(*c.lower, k) {
+			return true
+		}
+	}
+	return false
+}
+
+// Match describes why a post was detected: its content type, the name of
+// the rule that fired and how confident that rule is, from 0 to 1. URL is
+// the link that matched, for rules that match links.
+type Match struct {
+	Type       NYTContentType
+	Rule       string
+	Confidence float64
+	URL        string
+}
+
+// Detector recognises one kind of NYT content in a post. Name identifies
+// the detector so a detection rule can override or disable it.
+type Detector interface {
+	Detect(c Candidate) (Match, bool)
+	Name() string
+}
+
+// Registry runs a set of detectors over candidates, and exclusions over
+// what they detect. The zero value is an empty registry ready to use.
+type Registry struct {
+	mu         sync.RWMutex
+	detectors  []Detector
+	exclusions []Exclusion
+
+	excludedMu sync.Mutex
+	excluded   map[string]int64
+}
+
+// NewRegistry returns a registry holding detectors.
+func NewRegistry(detectors ...Detector) *Registry {
+	return &Registry{detectors: detectors}
+}
+
+// Register adds a detector to the registry.
+func (r *Registry) Register(d Detector) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.detectors = append(r.detectors, d)
+}
+
+// Detectors returns the detectors in the registry, in order.
+func (r *Registry) Detectors() []Detector {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return append([]Detector(nil), r.detectors...)
+}
+
+// Set replaces every detector in the registry at once, so concurrent calls
+// to Detect see either the old set or the new one.
+func (r *Registry) Set(detectors ...Detector) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.detectors = append([]Detector(nil), detectors...)
+}
+
+// SetExclusions replaces every exclusion in the registry at once.
+func (r *Registry) SetExclusions(exclusions ...Exclusion) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.exclusions = append([]Exclusion(nil), exclusions...)
+}
+
+// Exclude runs the exclusions over a candidate detected as m, returning the
+// name of the first that matches and why, or false if none do. Exclusions
+// are counted by name, see Excluded.
+func (r *Registry) Exclude(c Candidate, m Match) (name, reason string, excluded bool) {
+	r.mu.RLock()
+	exclusions := r.exclusions
+	r.mu.RUnlock()
+
+	for _, e := range exclusions {
+		if reason, ok := e.Excludes(c, m); ok {
+			r.excludedMu.Lock()
+			if r.excluded == nil {
+				r.excluded = make(map[string]int64)
+			}
+			r.excluded[e.Name]++
+			r.excludedMu.Unlock()
+			return e.Name, reason, true
+		}
+	}
+	return "", "", false
+}
+
+// Excluded returns how many posts each exclusion has rejected.
+func (r *Registry) Excluded() map[string]int64 {
+	r.excludedMu.Lock()
+	defer r.excludedMu.Unlock()
+	counts := make(map[string]int64, len(r.excluded))
+	for name, n := range r.excluded {
+		counts[name] = n
+	}
+	return counts
+}
+
+// Detect runs every detector and returns the most confident match; ties go
+// to the detector registered first.
+func (r *Registry) Detect(c Candidate) (Match, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	c.prepare()
+
+	var (
+		best  Match
+		found bool
+	)
+	for _, d := range r.detectors {
+		m, ok := d.Detect(c)
+		if ok && (!found || m.Confidence > best.Confidence) {
+			best, found = m, true
+		}
+	}
+	return best, found
+}
+
+var defaultRegistry = NewRegistry()
+
+// DefaultRegistry returns the registry the built-in detectors register
+// with, which Detect runs.
+func DefaultRegistry() *Registry {
+	return defaultRegistry
+}
+
+// Register adds a detector to the default registry. Detectors for each
+// content type register themselves from init in their own file.
+func Register(d Detector) {
+	defaultRegistry.Register(d)
+}
+
+// Detect runs the default registry over c.
+func Detect(c Candidate) (Match, bool) {
+	return defaultRegistry.Detect(c)
+}
+
+// Exclude runs the default registry's exclusions over c, detected as m.
+func Exclude(c Candidate, m Match) (name, reason string, excluded bool) {
+	return defaultRegistry.Exclude(c, m)
+}
+
+// RegexDetector matches the text of a post against a regular expression,
+// e.g. the share text of a game. When the expression has named groups, the
+// name of the first group that took part in the match is used as the
+// content type instead of Type, so one expression can tell apart variants
+// such as the Mini and the daily Crossword.
+//
+// Most posts match nothing, so when Keywords is set the expression only
+// runs on text containing one of them, ignoring case. Every match of the
+// expression must contain a keyword for this to be safe.
+type RegexDetector struct {
+	Type       NYTContentType
+	Rule       string
+	Regex      *regexp.Regexp
+	Keywords   []string
+	Confidence float64
+}
+
+func (d RegexDetector) Detect(c Candidate) (Match, bool) {
+	if !c.containsAny(d.Keywords) {
+		return Match{}, false
+	}
+	loc := d.Regex.FindStringSubmatchIndex(c.Text)
+	if loc == nil {
+		return Match{}, false
+	}
+	return Match{Type: d.matchedType(loc), Rule: d.Rule, Confidence: d.Confidence}, true
+}
+
+func (d RegexDetector) Name() string { return d.Rule }
+
+// matchedType returns the name of the first named group that matched, or
+// Type if there is none.
+func (d RegexDetector) matchedType(loc []int) NYTContentType {
+	for i, name := range d.Regex.SubexpNames() {
+		if name != "" && loc[2*i] >= 0 {
+			return NYTContentType(name)
+		}
+	}
+	return d.Type
+}
+
+// URLDetector matches the links in a post against a regular expression,
+// e.g. the domain of an NYT product. Links are matched in the form returned
+// by CanonicalURL.
+type URLDetector struct {
+	Type       NYTContentType
+	Rule       string
+	Regex      *regexp.Regexp
+	Confidence float64
+}
+
+func (d URLDetector) Detect(c Candidate) (Match, bool) {
+	c.prepare()
+	for _, u := range c.canonical {
+		if d.Regex.MatchString(u.canonical) {
+			return Match{Type: d.Type, Rule: d.Rule, Confidence: d.Confidence, URL: u.raw}, true
+		}
+	}
+	return Match{}, false
+}
+
+func (d URLDetector) Name() string { return d.Rule }
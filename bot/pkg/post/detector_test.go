@@ -0,0 +1,73 @@
+package post
+
+import (
+	"regexp"
+	"testing"
+)
+
+func TestRegistryDetect(t *testing.T) {
+	r := NewRegistry(
+		URLDetector{Type: Cooking, Rule: "cooking-link", Regex: regexp.MustCompile(`cooking\.nytimes\.com`), Confidence: 0.8},
+		RegexDetector{Type: Wordle, Rule: "wordle-share", Regex: regexp.MustCompile(`Wordle \d`), Confidence: 1},
+		RegexDetector{Type: Strands, Rule: "strands-share", Regex: regexp.MustCompile(`Strands #\d`), Confidence: 1},
+	)
+
+	tests := []struct {
+		name      string
+		candidate Candidate
+		want      Match
+		wantOK    bool
+	}{
+		{
+			name:      "text match",
+			candidate: Candidate{Text: "Wordle 1,236 4/6"},
+			want:      Match{Type: Wordle, Rule: "wordle-share", Confidence: 1},
+			wantOK:    true,
+		},
+		{
+			name:      "url match",
+			candidate: Candidate{Text: "dinner", URLs: []string{"https://example.com", "https://cooking.nytimes.com/recipes/1"}},
+			want:      Match{Type: Cooking, Rule: "cooking-link", Confidence: 0.8, URL: "https://cooking.nytimes.com/recipes/1"},
+			wantOK:    true,
+		},
+		{
+			name:      "most confident wins",
+			candidate: Candidate{Text: "Wordle 1,236 4/6", URLs: []string{"https://cooking.nytimes.com/recipes/1"}},
+			want:      Match{Type: Wordle, Rule: "wordle-share", Confidence: 1},
+			wantOK:    true,
+		},
+		{
+			name:      "ties go to the first registered",
+			candidate: Candidate{Text: "Strands #248 and Wordle 1,236 4/6"},
+			want:      Match{Type: Wordle, Rule: "wordle-share", Confidence: 1},
+			wantOK:    true,
+		},
+		{
+			name:      "no match",
+			candidate: Candidate{Text: "just a post", URLs: []string{"https://example.com"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := r.Detect(tt.candidate)
+			if ok != tt.wantOK || got != tt.want {
+				t.Errorf("Detect() = %v, %v, want %v, %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestRegexDetectorKeywords(t *testing.T) {
+	d := RegexDetector{Type: Wordle, Rule: "wordle-share", Regex: regexp.MustCompile(`(?i)wordle \d`), Keywords: []string{"wordle"}, Confidence: 1}
+
+	if _, ok := d.Detect(Candidate{Text: "WORDLE 1,236 4/6"}); !ok {
+		t.Error("Detect() should match when a keyword is present in any case")
+	}
+
+	// the prefilter is trusted: without a keyword the expression never runs
+	d.Keywords = []string{"strands"}
+	if _, ok := d.Detect(Candidate{Text: "Wordle 1,236 4/6"}); ok {
+		t.Error("Detect() should not run the expression without a keyword")
+	}
+}
@@ -0,0 +1,79 @@
+package post
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// Exclusion rejects a post that was detected but shouldn't be replied to,
+// e.g. one quoting a Cooking link critically, or from an account that only
+// talks about the games. A post is excluded if any of the criteria that are
+// set match.
+type Exclusion struct {
+	Name string
+	// Types limits the exclusion to posts detected as one of them; empty
+	// applies it to every type.
+	Types []NYTContentType
+	// Keywords are matched anywhere in the text, ignoring case, and must be
+	// lowercase.
+	Keywords []string
+	// Regex is matched against the text.
+	Regex *regexp.Regexp
+	// URLRegex is matched against each link in canonical form (see
+	// CanonicalURL).
+	URLRegex *regexp.Regexp
+	// Authors are handles or Bluesky DIDs, compared ignoring case and a
+	// leading "@" against both Candidate.Author and Candidate.AuthorID.
+	// Bluesky posts read from Jetstream carry only a DID, so an author
+	// listed by handle is not excluded from them.
+	Authors []string
+}
+
+// Excludes returns why c, detected as m, is excluded, or false if it isn't.
+func (e Exclusion) Excludes(c Candidate, m Match) (string, bool) {
+	if len(e.Types) > 0 && !slices.Contains(e.Types, m.Type) {
+		return "", false
+	}
+
+	c.prepare()
+	for _, k := range e.Keywords {
+		if strings.Contains(*c.lower, k) {
+			return fmt.Sprintf("keyword %q", k), true
+		}
+	}
+	if e.Regex != nil && e.Regex.MatchString(c.Text) {
+		return fmt.Sprintf("text matches %q", e.Regex), true
+	}
+	if e.URLRegex != nil {
+		for _, u := range c.canonical {
+			if e.URLRegex.MatchString(u.canonical) {
+				return fmt.Sprintf("link %s", u.raw), true
+			}
+		}
+	}
+	for _, author := range []string{c.Author, c.AuthorID} {
+		if e.excludesAuthor(author) {
+			return fmt.Sprintf("author %s", author), true
+		}
+	}
+	return "", false
+}
+
+func (e Exclusion) excludesAuthor(author string) bool {
+	author = normalizeHandle(author)
+	if author == "" {
+		return false
+	}
+	for _, a := range e.Authors {
+		if normalizeHandle(a) == author {
+			return true
+		}
+	}
+	return false
+}
+
+func normalizeHandle(h string) string {
+	return strings.ToLower(strings.TrimPrefix(strings.TrimSpace(h), "@"))
+}
@@ -0,0 +1,113 @@
+package post
+
+import (
+	"reflect"
+	"regexp"
+	"testing"
+)
+
+func TestExclusionExcludes(t *testing.T) {
+	wordle := Match{Type: Wordle, Rule: "wordle-share", Confidence: 1}
+	cooking := Match{Type: Cooking, Rule: "cooking-link", Confidence: 0.8}
+
+	tests := []struct {
+		name       string
+		exclusion  Exclusion
+		candidate  Candidate
+		match      Match
+		wantReason string
+	}{
+		{
+			name:       "keyword ignores case",
+			exclusion:  Exclusion{Name: "critics", Keywords: []string{"overrated"}},
+			candidate:  Candidate{Text: "This recipe is OVERRATED"},
+			match:      cooking,
+			wantReason: `keyword "overrated"`,
+		},
+		{
+			name:       "regex",
+			exclusion:  Exclusion{Name: "spoilers", Regex: regexp.MustCompile(`(?i)spoiler`)},
+			candidate:  Candidate{Text: "Wordle 1,236 4/6 spoiler: it's a bird"},
+			match:      wordle,
+			wantReason: `text matches "(?i)spoiler"`,
+		},
+		{
+			name:       "domain",
+			exclusion:  Exclusion{Name: "reposts", URLRegex: regexp.MustCompile(`^https://example\.com/`)},
+			candidate:  Candidate{URLs: []string{"https://cooking.nytimes.com/recipes/1", "https://www.example.com/copy"}},
+			match:      cooking,
+			wantReason: "link https://www.example.com/copy",
+		},
+		{
+			name:       "author ignores case and a leading @",
+			exclusion:  Exclusion{Name: "bots", Authors: []string{"@WordleBot@example.social"}},
+			candidate:  Candidate{Text: "Wordle 1,236 4/6", Author: "wordlebot@example.social"},
+			match:      wordle,
+			wantReason: "author wordlebot@example.social",
+		},
+		{
+			name:       "author by DID",
+			exclusion:  Exclusion{Name: "bots", Authors: []string{"did:plc:wordlebot"}},
+			candidate:  Candidate{Text: "Wordle 1,236 4/6", Author: "wordlebot.bsky.social", AuthorID: "did:plc:wordlebot"},
+			match:      wordle,
+			wantReason: "author did:plc:wordlebot",
+		},
+		{
+			name:       "jetstream author by DID",
+			exclusion:  Exclusion{Name: "bots", Authors: []string{"wordlebot.bsky.social", "did:plc:wordlebot"}},
+			candidate:  Candidate{Text: "Wordle 1,236 4/6", Author: "did:plc:wordlebot", AuthorID: "did:plc:wordlebot"},
+			match:      wordle,
+			wantReason: "author did:plc:wordlebot",
+		},
+		{
+			name:      "other types are not excluded",
+			exclusion: Exclusion{Name: "critics", Types: []NYTContentType{Cooking}, Keywords: []string{"overrated"}},
+			candidate: Candidate{Text: "Wordle 1,236 4/6, overrated game"},
+			match:     wordle,
+		},
+		{
+			name:      "nothing matches",
+			exclusion: Exclusion{Name: "bots", Authors: []string{"wordlebot@example.social"}},
+			candidate: Candidate{Text: "Wordle 1,236 4/6", Author: "someone@example.social"},
+			match:     wordle,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reason, ok := tt.exclusion.Excludes(tt.candidate, tt.match)
+			if ok != (tt.wantReason != "") || reason != tt.wantReason {
+				t.Errorf("Excludes() = %q, %v, want %q", reason, ok, tt.wantReason)
+			}
+		})
+	}
+}
+
+func TestRegistryExclude(t *testing.T) {
+	r := NewRegistry()
+	r.SetExclusions(
+		Exclusion{Name: "bots", Authors: []string{"wordlebot@example.social"}},
+		Exclusion{Name: "spoilers", Keywords: []string{"spoiler"}},
+	)
+	m := Match{Type: Wordle, Rule: "wordle-share", Confidence: 1}
+
+	candidates := []Candidate{
+		{Text: "Wordle 1,236 4/6", Author: "wordlebot@example.social"},
+		{Text: "Wordle 1,236 4/6 spoiler", Author: "wordlebot@example.social"},
+		{Text: "Wordle 1,236 4/6 spoiler"},
+		{Text: "Wordle 1,236 4/6"},
+	}
+	var names []string
+	for _, c := range candidates {
+		if name, _, ok := r.Exclude(c, m); ok {
+			names = append(names, name)
+		}
+	}
+
+	if want := []string{"bots", "bots", "spoilers"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("excluded by %v, want %v", names, want)
+	}
+	if got, want := r.Excluded(), map[string]int64{"bots": 2, "spoilers": 1}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Excluded() = %v, want %v", got, want)
+	}
+}
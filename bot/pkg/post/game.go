@@ -0,0 +1,210 @@
+package post
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// GameResult is what a game share says about the game played. Fields a
+// game doesn't report are left at their zero value, or nil where zero is a
+// result in itself.
+type GameResult struct {
+	// Puzzle is the puzzle number, e.g. 1236 for "Wordle 1,236".
+	Puzzle int `json:"puzzle,omitempty"`
+	// Score is the result as the game presents it, e.g. "4/6" for Wordle,
+	// "Genius" for Spelling Bee or "Hard" for Sudoku.
+	Score string `json:"score,omitempty"`
+	// Guesses is the number of guesses taken, for Wordle.
+	Guesses int `json:"guesses,omitempty"`
+	// Mistakes is the number of wrong guesses, for Connections.
+	Mistakes *int `json:"mistakes,omitempty"`
+	// Solved is set for games whose shares can record a loss, false for
+	// e.g. Wordle X/6 or running out of mistakes in Connections. Games
+	// that are only shared once solved leave it nil.
+	Solved *bool `json:"solved,omitempty"`
+	// HardMode is set for Wordle shares played in hard mode.
+	HardMode bool `json:"hard_mode,omitempty"`
+	// Date is the date of the puzzle as YYYY-MM-DD, either as shared or
+	// worked out from the puzzle number.
+	Date string `json:"date,omitempty"`
+	// SolveTime is how long the puzzle took, for games that share it.
+	SolveTime time.Duration `json:"solve_time,omitempty"`
+}
+
+// GameParser extracts a GameResult from the text of a share. It returns
+// false if the text doesn't contain one.
+type GameParser func(text string) (*GameResult, bool)
+
+var (
+	parsersMu sync.RWMutex
+	parsers   = map[NYTContentType]GameParser{}
+)
+
+// RegisterParser sets the parser for a content type. Parsers are keyed by
+// type rather than tied to a detector so that any rule detecting a game
+// gets its metadata. Like detectors, they register from init.
+func RegisterParser(t NYTContentType, p GameParser) {
+	parsersMu.Lock()
+	defer parsersMu.Unlock()
+	parsers[t] = p
+}
+
+// ParseGame parses the share text of a post detected as t, returning nil
+// if t isn't a game or the text can't be parsed.
+func ParseGame(t NYTContentType, text string) *GameResult {
+	parsersMu.RLock()
+	p, ok := parsers[t]
+	parsersMu.RUnlock()
+	if !ok {
+		return nil
+	}
+	g, ok := p(text)
+	if !ok {
+		return nil
+	}
+	if epoch, ok := puzzleEpochs[t]; ok && g.Date == "" && g.Puzzle > 0 {
+		g.Date = epoch.AddDate(0, 0, g.Puzzle).Format(time.DateOnly)
+	}
+	return g
+}
+
+// puzzleEpochs is the day before puzzle #1 of games that number their
+// puzzles daily, so a puzzle number can be turned into the puzzle's date.
+var puzzleEpochs = map[NYTContentType]time.Time{
+	Wordle:      time.Date(2021, time.June, 19, 0, 0, 0, 0, time.UTC),
+	Connections: time.Date(2023, time.June, 11, 0, 0, 0, 0, time.UTC),
+	Strands:     time.Date(2024, time.March, 3, 0, 0, 0, 0, time.UTC),
+}
+
+// numberPattern matches a puzzle number with or without grouping, in the
+// separators locales use for thousands: 999, 1234, 1,234, 1.234, 1 234
+// (including non-breaking and narrow spaces) and 1'234.
+const numberPattern = `[0-9]{1,3}(?:[,.'\x{00a0}\x{202f} ][0-9]{3})+|[0-9]+`
+
+// durationPattern matches a solve time such as 0:51, 12:34 or 1:02:03.
+const durationPattern = `[0-9]{1,2}(?::[0-9]{2}){1,2}`
+
+// datePattern matches a US month/day/year date such as 10/16/2024, as the
+// Crossword shares it, or an ISO 8601 date.
+const datePattern = `[0-9]{1,2}/[0-9]{1,2}/[0-9]{4}|[0-9]{4}-[0-9]{2}-[0-9]{2}`
+
+// parseNumber parses a number matched by numberPattern.
+func parseNumber(s string) (int, bool) {
+	s = strings.Map(func(r rune) rune {
+		if r >= '0' && r <= '9' {
+			return r
+		}
+		return -1
+	}, s)
+	n, err := strconv.Atoi(s)
+	return n, err == nil
+}
+
+// wordleGuesses is the number of guesses Wordle allows.
+const wordleGuesses = 6
+
+// parseGuesses parses the guesses half of a Wordle score, where X means the
+// puzzle wasn't solved.
+func parseGuesses(s string) (int, bool, bool) {
+	if strings.EqualFold(s, "X") {
+		return wordleGuesses, false, true
+	}
+	n, err := strconv.Atoi(s)
+	return n, true, err == nil
+}
+
+// connectionsMistakes is the number of mistakes Connections allows.
+const connectionsMistakes = 4
+
+// parseGrid counts the mistakes in a Connections grid, one row of coloured
+// squares per guess: a row that isn't all one colour is a mistake.
+func parseGrid(s string) (mistakes int, solved bool) {
+	for _, line := range strings.Split(s, "\n") {
+		row := []rune(strings.TrimSpace(line))
+		if len(row) == 0 {
+			continue
+		}
+		if strings.Count(string(row), string(row[0])) != len(row) {
+			mistakes++
+		}
+	}
+	return mistakes, mistakes < connectionsMistakes
+}
+
+// parseSolveTime parses a duration matched by durationPattern.
+func parseSolveTime(s string) (time.Duration, bool) {
+	var d time.Duration
+	for _, part := range strings.Split(s, ":") {
+		n, err := strconv.Atoi(part)
+		if err != nil {
+			return 0, false
+		}
+		d = d*60 + time.Duration(n)
+	}
+	return d * time.Second, true
+}
+
+// parseDate parses a date matched by datePattern, returning it as
+// YYYY-MM-DD.
+func parseDate(s string) (string, bool) {
+	for _, layout := range []string{"1/2/2006", time.DateOnly} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t.Format(time.DateOnly), true
+		}
+	}
+	return "", false
+}
+
+// regexParser returns a GameParser that matches the first of res that
+// matches the text and fills a GameResult from its named groups: puzzle,
+// score, guesses, grid, hard, date and time. A group that matched but can't be parsed
+// fails the parse.
+func regexParser(res ...*regexp.Regexp) GameParser {
+	return func(text string) (*GameResult, bool) {
+		for _, re := range res {
+			if m := re.FindStringSubmatch(text); m != nil {
+				return parseGroups(re, m)
+			}
+		}
+		return nil, false
+	}
+}
+
+// parseGroups fills a GameResult from the named groups of a match of re.
+func parseGroups(re *regexp.Regexp, m []string) (*GameResult, bool) {
+	var g GameResult
+	for i, name := range re.SubexpNames() {
+		v := m[i]
+		if name == "" || v == "" {
+			continue
+		}
+
+		ok := true
+		switch name {
+		case "puzzle":
+			g.Puzzle, ok = parseNumber(v)
+		case "score":
+			g.Score = strings.Join(strings.Fields(v), " ")
+		case "guesses":
+			var solved bool
+			g.Guesses, solved, ok = parseGuesses(v)
+			g.Solved = &solved
+		case "grid":
+			mistakes, solved := parseGrid(v)
+			g.Mistakes, g.Solved = &mistakes, &solved
+		case "hard":
+			g.HardMode = true
+		case "date":
+			g.Date, ok = parseDate(v)
+		case "time":
+			g.SolveTime, ok = parseSolveTime(v)
+		}
+		if !ok {
+			return nil, false
+		}
+	}
+	return &g, true
+}
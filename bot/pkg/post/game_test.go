@@ -0,0 +1,145 @@
+package post
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func ptr[T any](v T) *T { return &v }
+
+func TestParseGame(t *testing.T) {
+	tests := []struct {
+		name string
+		typ  NYTContentType
+		text string
+		want *GameResult
+	}{
+		{
+			name: "wordle",
+			typ:  Wordle,
+			text: "Wordle 1,236 4/6\n\n⬜🟨⬜⬜⬜\n🟩🟩🟩🟩🟩",
+			want: &GameResult{Puzzle: 1236, Score: "4/6", Guesses: 4, Solved: ptr(true), Date: "2024-11-06"},
+		},
+		{
+			name: "wordle hard mode",
+			typ:  Wordle,
+			text: "Wordle 1.245 3/6*\n\n🟩🟩🟩🟩🟩",
+			want: &GameResult{Puzzle: 1245, Score: "3/6", Guesses: 3, Solved: ptr(true), HardMode: true, Date: "2024-11-15"},
+		},
+		{
+			name: "wordle failed",
+			typ:  Wordle,
+			text: "Wordle 999 X/6\n\n⬛🟩🟩🟩🟩",
+			want: &GameResult{Puzzle: 999, Score: "X/6", Guesses: 6, Solved: ptr(false), Date: "2024-03-14"},
+		},
+		{
+			name: "wordle with a non-breaking space separator",
+			typ:  Wordle,
+			text: "Wordle 1\u00a0234 2/6",
+			want: &GameResult{Puzzle: 1234, Score: "2/6", Guesses: 2, Solved: ptr(true), Date: "2024-11-04"},
+		},
+		{
+			name: "connections",
+			typ:  Connections,
+			text: "Connections\nPuzzle #523\n🟨🟨🟨🟨\n🟩🟩🟩🟩\n🟦🟦🟦🟦\n🟪🟪🟪🟪\n",
+			want: &GameResult{Puzzle: 523, Mistakes: ptr(0), Solved: ptr(true), Date: "2024-11-15"},
+		},
+		{
+			name: "connections with mistakes",
+			typ:  Connections,
+			text: "Connections\nPuzzle #512\n🟨🟩🟨🟨\n🟨🟨🟨🟨\n🟩🟩🟩🟩\n🟦🟪🟦🟦\n🟦🟦🟦🟦\n🟪🟪🟪🟪\n",
+			want: &GameResult{Puzzle: 512, Mistakes: ptr(2), Solved: ptr(true), Date: "2024-11-04"},
+		},
+		{
+			name: "connections lost",
+			typ:  Connections,
+			text: "Connections\nPuzzle #789\n🟨🟩🟨🟨\n🟨🟨🟨🟨\n🟦🟪🟦🟦\n🟦🟪🟩🟦\n🟦🟪🟦🟩\n",
+			want: &GameResult{Puzzle: 789, Mistakes: ptr(4), Solved: ptr(false), Date: "2025-08-08"},
+		},
+		{
+			name: "connections sports edition",
+			typ:  ConnectionsSports,
+			text: "Connections: Sports Edition\nPuzzle #12\n🟨🟨🟨🟨\n",
+			want: &GameResult{Puzzle: 12, Mistakes: ptr(0), Solved: ptr(true)},
+		},
+		{
+			name: "strands",
+			typ:  Strands,
+			text: "Strands #1,050\n“Fix it”\n🔵🔵🟡🔵",
+			want: &GameResult{Puzzle: 1050, Date: "2027-01-17"},
+		},
+		{
+			name: "crossword",
+			typ:  Crossword,
+			text: "I solved the Wednesday 10/16/2024 New York Times Daily Crossword in 1:08:12!",
+			want: &GameResult{Date: "2024-10-16", SolveTime: time.Hour + 8*time.Minute + 12*time.Second},
+		},
+		{
+			name: "mini",
+			typ:  Mini,
+			text: "I solved the 1/5/2025 New York Times Mini Crossword in 0:51!",
+			want: &GameResult{Date: "2025-01-05", SolveTime: 51 * time.Second},
+		},
+		{
+			name: "spelling bee",
+			typ:  SpellingBee,
+			text: "I reached Queen Bee in today's Spelling Bee!",
+			want: &GameResult{Score: "Queen Bee"},
+		},
+		{
+			name: "spelling bee rank after other rank words",
+			typ:  SpellingBee,
+			text: "Good morning! Spelling Bee\nOctober 16, 2024\nRank: Genius",
+			want: &GameResult{Score: "Genius"},
+		},
+		{
+			name: "letter boxed",
+			typ:  LetterBoxed,
+			text: "I solved today's Letter Boxed in 2 words!",
+			want: &GameResult{Score: "2 words"},
+		},
+		{
+			name: "tiles",
+			typ:  Tiles,
+			text: "I completed today's Tiles in 3:12 with a 45 tile combo!",
+			want: &GameResult{Score: "45 tile combo", SolveTime: 3*time.Minute + 12*time.Second},
+		},
+		{
+			name: "vertex",
+			typ:  Vertex,
+			text: "I completed Vertex #512 in 2:31!",
+			want: &GameResult{Puzzle: 512, SolveTime: 2*time.Minute + 31*time.Second},
+		},
+		{
+			name: "sudoku",
+			typ:  Sudoku,
+			text: "I solved the Hard NYT Sudoku in 12:34!",
+			want: &GameResult{Score: "Hard", SolveTime: 12*time.Minute + 34*time.Second},
+		},
+		{
+			name: "sudoku difficulty after the name",
+			typ:  Sudoku,
+			text: "NYT Sudoku (Medium) done",
+			want: &GameResult{Score: "Medium"},
+		},
+		{
+			name: "not a game",
+			typ:  Cooking,
+			text: "Wordle 1,236 4/6",
+		},
+		{
+			name: "unparseable share",
+			typ:  Wordle,
+			text: "Wordle was hard today",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ParseGame(tt.typ, tt.text); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseGame() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
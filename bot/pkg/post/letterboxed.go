@@ -0,0 +1,25 @@
+package post
+
+import "regexp"
+
+// Letter Boxed shares give the number of words used, e.g. "I solved
+// today's Letter Boxed in 2 words!". Only "in N words" after the game's
+// name counts, so talk about solving it is not taken for a share.
+func init() {
+	Register(RegexDetector{
+		Type:       LetterBoxed,
+		Rule:       "letterboxed-share",
+		Regex:      regexp.MustCompile(`(?is)letter\s?boxed.{0,80}?\bin\s+[0-9]+\s+words?\b`),
+		Keywords:   []string{"letter"},
+		Confidence: 0.9,
+	})
+	Register(URLDetector{
+		Type:       LetterBoxed,
+		Rule:       "letterboxed-link",
+		Regex:      regexp.MustCompile(`(?i)nytimes\.com/puzzles/letter-boxed`),
+		Confidence: 0.8,
+	})
+	RegisterParser(LetterBoxed, regexParser(
+		regexp.MustCompile(`(?i)\bin\s+(?P<score>[0-9]+\s+words?)\b`),
+	))
+}
@@ -0,0 +1,99 @@
+package post
+
+import (
+	"net/url"
+	"strings"
+)
+
+// hostPrefixes are stripped from hosts so that mobile, AMP and www variants
+// of a link classify the same way.
+var hostPrefixes = []string{"www.", "mobile.", "amp.", "m."}
+
+// CanonicalURL normalises a link so detectors only have to match one form
+// of it: the scheme is always https, www/mobile/AMP host prefixes are
+// dropped, AMP paths are mapped back to the article, and the query string
+// (gift link codes, share and tracking parameters) and fragment are
+// removed. It returns false if raw is not an absolute http(s) URL.
+func CanonicalURL(raw string) (string, bool) {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", false
+	}
+
+	host := strings.ToLower(u.Hostname())
+	for _, prefix := range hostPrefixes {
+		host = strings.TrimPrefix(host, prefix)
+	}
+
+	path := u.EscapedPath()
+	path = strings.TrimPrefix(path, "/amp/")
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	path = strings.TrimSuffix(path, "/amp")
+	if strings.HasSuffix(path, ".amp.html") {
+		path = strings.TrimSuffix(path, ".amp.html") + ".html"
+	}
+
+	return "https://" + host + path, true
+}
+
+// IsGiftLink reports whether raw is an NYT gift link, which lets anyone
+// read the article without a subscription.
+func IsGiftLink(raw string) bool {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return false
+	}
+	q := u.Query()
+	return q.Has("unlocked_article_code") || q.Has("giftCopy") || q.Get("smid") == "url-share-gift"
+}
+
+// Link describes an NYT link a post was detected by.
+type Link struct {
+	// URL is the canonical form of the link, see CanonicalURL.
+	URL string `json:"url"`
+	// Section is the section of the site the link is in, e.g. "us" for
+	// nytimes.com/2024/10/16/us/... or "reviews" for a Wirecutter review.
+	Section string `json:"section,omitempty"`
+	// Gift is set for gift links, see IsGiftLink.
+	Gift bool `json:"gift,omitempty"`
+}
+
+// ParseLink describes the link raw, or returns nil if it isn't an http(s)
+// URL.
+func ParseLink(raw string) *Link {
+	u, ok := CanonicalURL(raw)
+	if !ok {
+		return nil
+	}
+	return &Link{URL: u, Section: section(u), Gift: IsGiftLink(raw)}
+}
+
+// productPrefixes are the paths NYT products and page types live under on
+// nytimes.com, which come before the section.
+var productPrefixes = map[string]bool{
+	"athletic":    true,
+	"wirecutter":  true,
+	"interactive": true,
+	"section":     true,
+}
+
+// section returns the first path segment of a canonical URL that is
+// neither a product prefix nor a number (an ID or part of a date), unless
+// it is the last segment, which names the page itself. Section fronts such
+// as nytimes.com/section/politics are the exception.
+func section(canonical string) string {
+	_, path, _ := strings.Cut(strings.TrimPrefix(canonical, "https://"), "/")
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	for i, s := range segments {
+		if productPrefixes[s] || strings.Trim(s, "0123456789") == "" {
+			continue
+		}
+		if i == len(segments)-1 && segments[0] != "section" {
+			return ""
+		}
+		return s
+	}
+	return ""
+}
@@ -0,0 +1,111 @@
+package post
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCanonicalURL(t *testing.T) {
+	tests := []struct {
+		raw  string
+		want string
+		ok   bool
+	}{
+		{"https://www.nytimes.com/2024/10/16/us/example.html", "https://nytimes.com/2024/10/16/us/example.html", true},
+		{"http://mobile.nytimes.com/2024/10/16/us/example.html", "https://nytimes.com/2024/10/16/us/example.html", true},
+		{"https://www.nytimes.com/2024/10/16/us/example.amp.html", "https://nytimes.com/2024/10/16/us/example.html", true},
+		{"https://amp.nytimes.com/2024/10/16/us/example.html", "https://nytimes.com/2024/10/16/us/example.html", true},
+		{"https://www.nytimes.com/2024/10/16/us/example.html?unlocked_article_code=1.abc.def&smid=url-share", "https://nytimes.com/2024/10/16/us/example.html", true},
+		{"https://www.nytimes.com/athletic/5812345/2024/10/16/example/amp", "https://nytimes.com/athletic/5812345/2024/10/16/example", true},
+		{"https://WWW.NYTIMES.COM/wirecutter/reviews/best-example/#section", "https://nytimes.com/wirecutter/reviews/best-example/", true},
+		{"mailto:someone@example.com", "", false},
+		{"/2024/10/16/us/example.html", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.raw, func(t *testing.T) {
+			got, ok := CanonicalURL(tt.raw)
+			if got != tt.want || ok != tt.ok {
+				t.Errorf("CanonicalURL() = %q, %v, want %q, %v", got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
+
+func TestIsGiftLink(t *testing.T) {
+	tests := []struct {
+		raw  string
+		want bool
+	}{
+		{"https://www.nytimes.com/2024/10/16/us/example.html?unlocked_article_code=1.abc.def", true},
+		{"https://www.nytimes.com/2024/10/16/us/example.html?smid=url-share-gift", true},
+		{"https://www.nytimes.com/2024/10/16/us/example.html?smid=url-share", false},
+		{"https://www.nytimes.com/2024/10/16/us/example.html", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.raw, func(t *testing.T) {
+			if got := IsGiftLink(tt.raw); got != tt.want {
+				t.Errorf("IsGiftLink() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseLink(t *testing.T) {
+	tests := []struct {
+		raw  string
+		want *Link
+	}{
+		{"https://www.nytimes.com/2024/10/16/us/politics/example.html", &Link{URL: "https://nytimes.com/2024/10/16/us/politics/example.html", Section: "us"}},
+		{"https://www.nytimes.com/2024/10/16/us/example.html?unlocked_article_code=1.abc.def", &Link{URL: "https://nytimes.com/2024/10/16/us/example.html", Section: "us", Gift: true}},
+		{"https://www.nytimes.com/interactive/2024/10/16/upshot/example.html", &Link{URL: "https://nytimes.com/interactive/2024/10/16/upshot/example.html", Section: "upshot"}},
+		{"https://www.nytimes.com/section/politics", &Link{URL: "https://nytimes.com/section/politics", Section: "politics"}},
+		{"https://www.nytimes.com/wirecutter/reviews/best-example/", &Link{URL: "https://nytimes.com/wirecutter/reviews/best-example/", Section: "reviews"}},
+		{"https://www.nytimes.com/athletic/5812345/2024/10/16/example/", &Link{URL: "https://nytimes.com/athletic/5812345/2024/10/16/example/"}},
+		{"https://cooking.nytimes.com/recipes/1025063-example", &Link{URL: "https://cooking.nytimes.com/recipes/1025063-example", Section: "recipes"}},
+		{"", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.raw, func(t *testing.T) {
+			if got := ParseLink(tt.raw); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseLink() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDetectLinks(t *testing.T) {
+	tests := []struct {
+		url  string
+		want NYTContentType
+	}{
+		{"https://cooking.nytimes.com/recipes/1025063-example", Cooking},
+		{"https://www.nytimes.com/2024/10/16/us/politics/example.html", Article},
+		{"https://www.nytimes.com/2024/10/16/us/politics/example.amp.html", Article},
+		{"https://www.nytimes.com/2024/10/16/us/politics/example.html?unlocked_article_code=1.abc.def&smid=url-share", Article},
+		{"https://www.nytimes.com/interactive/2024/10/16/upshot/example.html", Article},
+		{"https://nyti.ms/3abcDEF", Article},
+		{"https://www.nytimes.com/athletic/5812345/2024/10/16/example/", Athletic},
+		{"https://theathletic.com/5812345/2024/10/16/example/", Athletic},
+		{"https://www.nytimes.com/wirecutter/reviews/best-example/", Wirecutter},
+		{"https://thewirecutter.com/reviews/best-example/", Wirecutter},
+		{"https://www.nytimes.com/2024/10/16/podcasts/the-daily/example.html", Audio},
+		{"https://www.nytimes.com/audio/listen/example", Audio},
+		{"https://www.nytimes.com/section/politics", ""},
+		{"https://example.com/2024/10/16/us/example.html", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			got, ok := Detect(Candidate{URLs: []string{tt.url}})
+			if ok != (tt.want != "") || got.Type != tt.want {
+				t.Errorf("Detect() = %v, %v, want type %q", got, ok, tt.want)
+			}
+			if ok && got.URL != tt.url {
+				t.Errorf("Detect() URL = %q, want %q", got.URL, tt.url)
+			}
+		})
+	}
+}
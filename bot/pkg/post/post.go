@@ -1,49 +1,108 @@
 package post
 
 import (
-	"strings"
+	"time"
 )
 
 const (
-	Connections NYTContentType = "connections"
-	Crossword   NYTContentType = "crossword"
-	Wordle      NYTContentType = "wordle"
-	Strands     NYTContentType = "strands"
-	Cooking     NYTContentType = "cooking"
+	Connections       NYTContentType = "connections"
+	ConnectionsSports NYTContentType = "connections-sports"
+	Crossword         NYTContentType = "crossword"
+	Mini              NYTContentType = "mini"
+	Wordle            NYTContentType = "wordle"
+	Strands           NYTContentType = "strands"
+	SpellingBee       NYTContentType = "spellingbee"
+	LetterBoxed       NYTContentType = "letterboxed"
+	Tiles             NYTContentType = "tiles"
+	Vertex            NYTContentType = "vertex"
+	Sudoku            NYTContentType = "sudoku"
+	Cooking           NYTContentType = "cooking"
+	Article           NYTContentType = "article"
+	Athletic          NYTContentType = "athletic"
+	Wirecutter        NYTContentType = "wirecutter"
+	Audio             NYTContentType = "audio"
 
 	BlueSky  APISource = "bluesky"
 	Mastodon APISource = "mastodon"
 )
 
-// Where the type can be one of Strands, Connections, Wordle, Crossword, or Cooking
+// Where the type is one of the NYT Games (e.g. Wordle, Connections, Spelling Bee),
+// or a link to Cooking, a news Article, The Athletic, Wirecutter or NYT Audio
 type NYTContentType string
 
 type APISource string
 
 type Post struct {
-	ID      string
-	URI     string
-	Content string
-	Source  APISource
-	Type    NYTContentType
+	ID      string         `json:"id"`
+	URI     string         `json:"uri"`
+	Content string         `json:"content"`
+	Source  APISource      `json:"source"`
+	Type    NYTContentType `json:"type"`
+
+	// DetectedAt is when the bot matched the post, Author the handle of whoever
+	// wrote it, Language its BCP 47 language tag if the source provides one,
+	// and Rule the detection rule that matched.
+	DetectedAt time.Time `json:"detected_at"`
+	Author     string    `json:"author,omitempty"`
+	Language   string    `json:"language,omitempty"`
+	Rule       string    `json:"rule,omitempty"`
+
+	// Game is what a game share says about the game played, or nil if the
+	// post isn't a game share or its result couldn't be parsed. Link is the
+	// NYT link the post was detected by, if any.
+	Game *GameResult `json:"game,omitempty"`
+	Link *Link       `json:"link,omitempty"`
 }
 
-func GetHashtagsFromTypes() []string {
-	hashTags := []string{
-		string(Cooking),
-		string(Wordle),
-		string(Strands),
-		string(Connections),
-		string(Crossword),
+// ContentTypes returns every NYTContentType the bot looks for.
+func ContentTypes() []NYTContentType {
+	return []NYTContentType{
+		Cooking,
+		Wordle,
+		Strands,
+		Connections,
+		Crossword,
+		Mini,
+		SpellingBee,
+		LetterBoxed,
+		Tiles,
+		Vertex,
+		Sudoku,
+		ConnectionsSports,
+		Article,
+		Athletic,
+		Wirecutter,
+		Audio,
 	}
-	return hashTags
 }
 
-func GetContentType(content string, groupNames []string) NYTContentType {
-	for _, name := range groupNames {
-		if strings.Contains(strings.ToLower(content), name) {
-			return NYTContentType(name)
+// hashtags maps a content type to the hashtag streamed for it. Games whose
+// own name is too generic a tag (#mini, #tiles, #sudoku) use the NYT
+// prefixed tag players share them with; articles and audio have no useful
+// tag and are only found through the public stream.
+var hashtags = map[NYTContentType]string{
+	Cooking:           "cooking",
+	Wordle:            "wordle",
+	Strands:           "strands",
+	Connections:       "connections",
+	Crossword:         "crossword",
+	Mini:              "nytmini",
+	SpellingBee:       "spellingbee",
+	LetterBoxed:       "letterboxed",
+	Tiles:             "nyttiles",
+	Vertex:            "nytvertex",
+	Sudoku:            "nytsudoku",
+	ConnectionsSports: "connectionssportsedition",
+	Athletic:          "theathletic",
+	Wirecutter:        "wirecutter",
+}
+
+func GetHashtagsFromTypes() []string {
+	var hashTags []string
+	for _, t := range ContentTypes() {
+		if tag, ok := hashtags[t]; ok {
+			hashTags = append(hashTags, tag)
 		}
 	}
-	return "no name"
+	return hashTags
 }
@@ -0,0 +1,218 @@
+package post
+
+import "testing"
+
+// TestDetectShares runs the default registry over share texts as each game
+// produces them, so a change to a detector that breaks a real share is
+// caught here.
+func TestDetectShares(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want NYTContentType
+	}{
+		{
+			name: "wordle",
+			text: "Wordle 1,236 4/6\n\n⬜🟨⬜⬜⬜\n⬜🟨⬜⬜⬜\n🟩🟩🟩🟩⬜\n🟩🟩🟩🟩🟩",
+			want: Wordle,
+		},
+		{
+			name: "wordle hard mode",
+			text: "Wordle 1,245 3/6*\n\n⬛⬛🟨⬛⬛\n🟨🟩⬛⬛🟩\n🟩🟩🟩🟩🟩",
+			want: Wordle,
+		},
+		{
+			name: "wordle failed",
+			text: "Wordle 1,240 X/6\n\n⬛⬛⬛⬛⬛\n⬛🟨⬛⬛⬛\n⬛🟩🟩⬛⬛\n🟩🟩🟩⬛⬛\n🟩🟩🟩⬛🟩\n🟩🟩🟩⬛🟩",
+			want: Wordle,
+		},
+		{
+			name: "wordle below 1,000",
+			text: "Wordle 999 5/6\n\n⬛⬛🟨⬛⬛\n🟨🟩⬛⬛🟩\n🟩🟩🟩🟩🟩",
+			want: Wordle,
+		},
+		{
+			name: "wordle without a thousands separator",
+			text: "Wordle 1234 4/6\n\n⬛⬛🟨⬛⬛\n🟨🟩⬛⬛🟩\n🟩🟩🟩🟩🟩",
+			want: Wordle,
+		},
+		{
+			name: "wordle with a period separator",
+			text: "Wordle 1.234 4/6\n\n⬛⬛🟨⬛⬛\n🟨🟩⬛⬛🟩\n🟩🟩🟩🟩🟩",
+			want: Wordle,
+		},
+		{
+			name: "wordle with a narrow space separator",
+			text: "Wordle 1\u202f234 4/6\n\n⬛⬛🟨⬛⬛\n🟨🟩⬛⬛🟩\n🟩🟩🟩🟩🟩",
+			want: Wordle,
+		},
+		{
+			name: "connections",
+			text: "Connections\nPuzzle #523\n🟨🟨🟨🟨\n🟩🟩🟩🟩\n🟦🟦🟦🟦\n🟪🟪🟪🟪\n",
+			want: Connections,
+		},
+		{
+			name: "connections with mistakes",
+			text: "Connections\nPuzzle #512\n🟨🟩🟨🟨\n🟨🟨🟨🟨\n🟩🟩🟩🟩\n🟦🟪🟦🟦\n🟦🟦🟦🟦\n🟪🟪🟪🟪\n",
+			want: Connections,
+		},
+		{
+			name: "connections with digits above 6",
+			text: "Connections\nPuzzle #789\n🟨🟨🟨🟨\n🟩🟩🟩🟩\n🟦🟦🟦🟦\n🟪🟪🟪🟪\n",
+			want: Connections,
+		},
+		{
+			name: "connections above 1,000",
+			text: "Connections\nPuzzle #1,002\n🟨🟨🟨🟨\n🟩🟩🟩🟩\n🟦🟦🟦🟦\n🟪🟪🟪🟪\n",
+			want: Connections,
+		},
+		{
+			name: "strands with a zero",
+			text: "Strands #250\n“Fix it”\n🔵🔵🟡🔵\n🔵🔵",
+			want: Strands,
+		},
+		{
+			name: "strands",
+			text: "Strands #248\n“Strumming right along ...”\n🟡🔵🔵🔵\n🔵🔵🔵🔵",
+			want: Strands,
+		},
+		{
+			name: "strands with hints",
+			text: "Strands #251\n“Fire it up!”\n💡🔵🔵💡\n🔵🟡🔵🔵",
+			want: Strands,
+		},
+		{
+			name: "daily crossword",
+			text: "I solved the Wednesday 10/16/2024 New York Times Daily Crossword in 8:12! https://www.nytimes.com/crosswords/game/daily/2024/10/16",
+			want: Crossword,
+		},
+		{
+			name: "crossword without weekday",
+			text: "I solved the 11/05/2024 New York Times Crossword in 22:07!",
+			want: Crossword,
+		},
+		{
+			name: "mini",
+			text: "I solved the 10/16/2024 New York Times Mini Crossword in 0:51! https://www.nytimes.com/crosswords/game/mini/2024/10/16",
+			want: Mini,
+		},
+		{
+			name: "mini with single digit date",
+			text: "I solved the 9/3/2024 New York Times Mini Crossword in 1:20!",
+			want: Mini,
+		},
+		{
+			name: "spelling bee",
+			text: "I reached Genius in today's Spelling Bee! 🐝 https://www.nytimes.com/puzzles/spelling-bee",
+			want: SpellingBee,
+		},
+		{
+			name: "spelling bee rank line",
+			text: "Spelling Bee\nOctober 16, 2024\nRank: Queen Bee 👑",
+			want: SpellingBee,
+		},
+		{
+			name: "letter boxed",
+			text: "I solved today's Letter Boxed in 2 words! #letterboxed",
+			want: LetterBoxed,
+		},
+		{
+			name: "tiles",
+			text: "I completed today's Tiles in 3:12 with a 45 tile combo!",
+			want: Tiles,
+		},
+		{
+			name: "vertex",
+			text: "I completed Vertex #512 in 2:31! https://www.nytimes.com/puzzles/vertex",
+			want: Vertex,
+		},
+		{
+			name: "sudoku",
+			text: "I solved the Hard NYT Sudoku in 12:34!",
+			want: Sudoku,
+		},
+		{
+			name: "connections sports edition",
+			text: "Connections: Sports Edition\nPuzzle #12\n🟨🟨🟨🟨\n🟩🟩🟩🟩\n🟦🟦🟦🟦\n🟪🟪🟪🟪\n",
+			want: ConnectionsSports,
+		},
+		{
+			name: "mentions wordle without sharing",
+			text: "Does anyone else play Wordle every morning?",
+		},
+		{
+			name: "mentions the spelling bee without sharing",
+			text: "My kid has a spelling bee at school tomorrow",
+		},
+		{
+			name: "spelling bee near a rank word",
+			text: "the spelling bee at my kid's school was great",
+		},
+		{
+			name: "spelling bee with a good result",
+			text: "Nice work everyone at the regional spelling bee, solid turnout and a great final round",
+		},
+		{
+			name: "mentions letter boxed without sharing",
+			text: "I haven't solved today's Letter Boxed yet, any hints?",
+		},
+		{
+			name: "letter boxed near a word count",
+			text: "Letter Boxed is hard, I keep getting stuck at 3 words",
+		},
+		{
+			name: "mentions tiles without sharing",
+			text: "Retiling the bathroom: these tiles are a great combo with the grout",
+		},
+		{
+			name: "mentions sudoku without sharing",
+			text: "Sudoku on the train again this morning",
+		},
+		{
+			name: "mentions the crossword without sharing",
+			text: "The New York Times Crossword was brutal today",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := Detect(Candidate{Text: tt.text})
+			if ok != (tt.want != "") || got.Type != tt.want {
+				t.Errorf("Detect() = %v, %v, want type %q", got, ok, tt.want)
+			}
+		})
+	}
+}
+
+func TestDetectGameLinks(t *testing.T) {
+	tests := []struct {
+		url  string
+		want NYTContentType
+	}{
+		{"https://www.nytimes.com/puzzles/spelling-bee", SpellingBee},
+		{"https://www.nytimes.com/puzzles/letter-boxed", LetterBoxed},
+		{"https://www.nytimes.com/puzzles/tiles", Tiles},
+		{"https://www.nytimes.com/puzzles/vertex", Vertex},
+		{"https://www.nytimes.com/puzzles/sudoku/hard", Sudoku},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			got, ok := Detect(Candidate{URLs: []string{tt.url}})
+			if !ok || got.Type != tt.want {
+				t.Errorf("Detect() = %v, %v, want type %q", got, ok, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetHashtagsFromTypes(t *testing.T) {
+	for _, tag := range GetHashtagsFromTypes() {
+		for _, r := range tag {
+			if (r < 'a' || r > 'z') && (r < '0' || r > '9') {
+				t.Errorf("hashtag %q should only contain lowercase letters and digits", tag)
+				break
+			}
+		}
+	}
+}
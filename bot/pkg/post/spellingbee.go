@@ -0,0 +1,32 @@
+package post
+
+import "regexp"
+
+// spellingBeeRanks are the ranks a Spelling Bee share can name. Several of
+// them are ordinary words, so a rank only counts after "Rank:" or "reached".
+const spellingBeeRanks = `beginner|good start|moving up|good|solid|nice|great|amazing|genius|queen bee`
+
+var spellingBeeShare = regexp.MustCompile(`(?is)spelling\s?bee.{0,80}?(?:\brank:\s*|\breached\s+)(?:` + spellingBeeRanks + `)\b` +
+	`|\breached\s+(?:` + spellingBeeRanks + `)\b.{0,80}?spelling\s?bee`)
+
+// Spelling Bee shares name the rank reached, e.g. "I reached Genius in
+// today's Spelling Bee!" or "Spelling Bee\nRank: Amazing", and often link
+// to the puzzle. The rank is the score.
+func init() {
+	Register(RegexDetector{
+		Type:       SpellingBee,
+		Rule:       "spellingbee-share",
+		Regex:      spellingBeeShare,
+		Keywords:   []string{"spelling"},
+		Confidence: 0.9,
+	})
+	Register(URLDetector{
+		Type:       SpellingBee,
+		Rule:       "spellingbee-link",
+		Regex:      regexp.MustCompile(`(?i)nytimes\.com/puzzles/spelling-bee`),
+		Confidence: 0.8,
+	})
+	RegisterParser(SpellingBee, regexParser(
+		regexp.MustCompile(`(?i)(?:\brank:\s*|\breached\s+)(?P<score>`+spellingBeeRanks+`)\b`),
+	))
+}
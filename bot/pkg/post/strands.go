@@ -0,0 +1,18 @@
+package post
+
+import "regexp"
+
+// Strands shares look like "Strands #248" followed by the theme and a row of
+// hint and theme word circles.
+func init() {
+	Register(RegexDetector{
+		Type:       Strands,
+		Rule:       "strands-share",
+		Regex:      regexp.MustCompile(`Strands\s#(?:` + numberPattern + `)`),
+		Keywords:   []string{"strands"},
+		Confidence: 1,
+	})
+	RegisterParser(Strands, regexParser(
+		regexp.MustCompile(`Strands\s#(?P<puzzle>`+numberPattern+`)`),
+	))
+}
@@ -0,0 +1,25 @@
+package post
+
+import "regexp"
+
+// Sudoku shares give the difficulty and solve time, e.g. "I solved the
+// Hard NYT Sudoku in 12:34!". The difficulty is the score.
+func init() {
+	Register(RegexDetector{
+		Type:       Sudoku,
+		Rule:       "sudoku-share",
+		Regex:      regexp.MustCompile(`(?i)\b(?:easy|medium|hard)\s+(?:NYT|New York Times)\s+sudoku\b|(?:NYT|New York Times)\s+sudoku\s+\(?(?:easy|medium|hard)\b`),
+		Keywords:   []string{"sudoku"},
+		Confidence: 0.9,
+	})
+	Register(URLDetector{
+		Type:       Sudoku,
+		Rule:       "sudoku-link",
+		Regex:      regexp.MustCompile(`(?i)nytimes\.com/puzzles/sudoku`),
+		Confidence: 0.8,
+	})
+	RegisterParser(Sudoku, regexParser(
+		regexp.MustCompile(`(?is)\b(?P<score>easy|medium|hard)\s+(?:NYT|New York Times)\s+sudoku\b(?:.{0,80}?\bin\s(?P<time>`+durationPattern+`)\b)?`),
+		regexp.MustCompile(`(?is)(?:NYT|New York Times)\s+sudoku\s+\(?(?P<score>easy|medium|hard)\b(?:.{0,80}?\bin\s(?P<time>`+durationPattern+`)\b)?`),
+	))
+}
@@ -0,0 +1,94 @@
+package post
+
+import (
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// blockElements start on a new line when converting HTML to text.
+var blockElements = map[string]bool{
+	"blockquote": true,
+	"div":        true,
+	"h1":         true,
+	"h2":         true,
+	"h3":         true,
+	"h4":         true,
+	"h5":         true,
+	"h6":         true,
+	"li":         true,
+	"ol":         true,
+	"pre":        true,
+	"ul":         true,
+}
+
+// HTMLToText converts post HTML, as Mastodon serves status content, into
+// the same plain text other sources provide: <br> becomes a newline,
+// paragraphs are separated by a blank line, entities are decoded and
+// markup is dropped. Link text is kept in full, including the parts of a
+// URL Mastodon hides with CSS. The result is passed through NormalizeText.
+func HTMLToText(s string) string {
+	nodes, err := html.ParseFragment(strings.NewReader(s), &html.Node{
+		Type:     html.ElementNode,
+		Data:     "div",
+		DataAtom: atom.Div,
+	})
+	if err != nil {
+		return NormalizeText(s)
+	}
+
+	var b strings.Builder
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		switch n.Type {
+		case html.TextNode:
+			b.WriteString(n.Data)
+			return
+		case html.ElementNode:
+			switch {
+			case n.Data == "br":
+				b.WriteString("\n")
+				return
+			case n.Data == "p":
+				b.WriteString("\n\n")
+			case blockElements[n.Data]:
+				b.WriteString("\n")
+			}
+		}
+
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+
+		if n.Type == html.ElementNode && (n.Data == "p" || blockElements[n.Data]) {
+			b.WriteString("\n")
+		}
+	}
+	for _, n := range nodes {
+		walk(n)
+	}
+
+	return NormalizeText(b.String())
+}
+
+var blankLines = regexp.MustCompile(`\n{3,}`)
+
+// NormalizeText puts plain post text into canonical form so detectors only
+// have to handle one: line endings become "\n", trailing whitespace is
+// removed from each line, runs of blank lines collapse into one, and
+// leading and trailing whitespace is trimmed. Spaces within a line,
+// including non-breaking ones used as thousands separators, are kept.
+func NormalizeText(s string) string {
+	s = strings.ReplaceAll(s, "\r\n", "\n")
+	s = strings.ReplaceAll(s, "\r", "\n")
+
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, " \t")
+	}
+	s = strings.Join(lines, "\n")
+
+	return strings.TrimSpace(blankLines.ReplaceAllString(s, "\n\n"))
+}
@@ -0,0 +1,71 @@
+package post
+
+import "testing"
+
+func TestHTMLToText(t *testing.T) {
+	tests := []struct {
+		name string
+		html string
+		want string
+	}{
+		{
+			name: "paragraphs and line breaks",
+			html: "<p>Wordle 1,236 4/6</p><p>⬜🟧⬜⬜⬜<br />🟧🟧🟧🟧🟧</p>",
+			want: "Wordle 1,236 4/6\n\n⬜🟧⬜⬜⬜\n🟧🟧🟧🟧🟧",
+		},
+		{
+			name: "connections",
+			html: "<p>Connections<br>Puzzle #523<br>🟨🟨🟨🟨<br>🟩🟩🟩🟩</p>",
+			want: "Connections\nPuzzle #523\n🟨🟨🟨🟨\n🟩🟩🟩🟩",
+		},
+		{
+			name: "entities",
+			html: "<p>Tom &amp; Jerry&#39;s &quot;mini&quot; &lt;3 &#x1F7E9;</p>",
+			want: "Tom & Jerry's \"mini\" <3 🟩",
+		},
+		{
+			name: "links keep their hidden text",
+			html: `<p>Dinner <a href="https://cooking.nytimes.com/recipes/1025063"><span class="invisible">https://</span><span class="ellipsis">cooking.nytimes.com/recipes/10</span><span class="invisible">25063</span></a></p>`,
+			want: "Dinner https://cooking.nytimes.com/recipes/1025063",
+		},
+		{
+			name: "mentions and hashtags",
+			html: `<p><span class="h-card"><a href="https://mastodon.social/@a" class="u-url mention">@<span>a</span></a></span> <a href="https://mastodon.social/tags/wordle" class="mention hashtag" rel="tag">#<span>wordle</span></a></p>`,
+			want: "@a #wordle",
+		},
+		{
+			name: "plain text",
+			html: "Strands #248",
+			want: "Strands #248",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := HTMLToText(tt.html); got != tt.want {
+				t.Errorf("HTMLToText() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalizeText(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{"crlf", "Connections\r\nPuzzle #523\r\n🟨🟨🟨🟨", "Connections\nPuzzle #523\n🟨🟨🟨🟨"},
+		{"trailing spaces", "Wordle 1,236 4/6  \n\n⬜🟧⬜⬜⬜ ", "Wordle 1,236 4/6\n\n⬜🟧⬜⬜⬜"},
+		{"blank lines", "Strands #248\n\n\n\n🔵🔵", "Strands #248\n\n🔵🔵"},
+		{"non-breaking space", "Wordle 1\u00a0236 4/6", "Wordle 1\u00a0236 4/6"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := NormalizeText(tt.text); got != tt.want {
+				t.Errorf("NormalizeText() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,25 @@
+package post
+
+import "regexp"
+
+// Tiles shares report the longest combo, e.g. "I completed today's Tiles
+// in 3:12 with a 45 tile combo!". Only "N tile combo" counts, as tiles and
+// combos come up in plenty of posts that aren't about the game.
+func init() {
+	Register(RegexDetector{
+		Type:       Tiles,
+		Rule:       "tiles-share",
+		Regex:      regexp.MustCompile(`(?is)\btiles\b.{0,80}?\b[0-9]+\s+tile\s+combo\b`),
+		Keywords:   []string{"tiles"},
+		Confidence: 0.9,
+	})
+	Register(URLDetector{
+		Type:       Tiles,
+		Rule:       "tiles-link",
+		Regex:      regexp.MustCompile(`(?i)nytimes\.com/puzzles/tiles`),
+		Confidence: 0.8,
+	})
+	RegisterParser(Tiles, regexParser(
+		regexp.MustCompile(`(?is)(?:\bin\s(?P<time>`+durationPattern+`)\b.{0,80}?)?\b(?P<score>[0-9]+\s+tile\s+combo)\b`),
+	))
+}
@@ -0,0 +1,24 @@
+package post
+
+import "regexp"
+
+// Vertex shares carry the puzzle number, e.g. "I completed Vertex #512 in
+// 2:31!".
+func init() {
+	Register(RegexDetector{
+		Type:       Vertex,
+		Rule:       "vertex-share",
+		Regex:      regexp.MustCompile(`(?i)\bvertex\s#(?:` + numberPattern + `)`),
+		Keywords:   []string{"vertex"},
+		Confidence: 0.9,
+	})
+	Register(URLDetector{
+		Type:       Vertex,
+		Rule:       "vertex-link",
+		Regex:      regexp.MustCompile(`(?i)nytimes\.com/puzzles/vertex`),
+		Confidence: 0.8,
+	})
+	RegisterParser(Vertex, regexParser(
+		regexp.MustCompile(`(?is)\bvertex\s#(?P<puzzle>`+numberPattern+`)(?:.{0,80}?\bin\s(?P<time>`+durationPattern+`)\b)?`),
+	))
+}
@@ -0,0 +1,14 @@
+package post
+
+import "regexp"
+
+// Wirecutter lives at nytimes.com/wirecutter, with thewirecutter.com links
+// still in circulation.
+func init() {
+	Register(URLDetector{
+		Type:       Wirecutter,
+		Rule:       "wirecutter-link",
+		Regex:      regexp.MustCompile(`^https://(?:nytimes\.com/wirecutter/|thewirecutter\.com/)`),
+		Confidence: 0.8,
+	})
+}
@@ -0,0 +1,19 @@
+package post
+
+import "regexp"
+
+// Wordle shares look like "Wordle 1,236 4/6" followed by the grid, with a
+// "*" after the score in hard mode. The puzzle number is grouped however
+// the player's locale groups thousands, or not at all.
+func init() {
+	Register(RegexDetector{
+		Type:       Wordle,
+		Rule:       "wordle-share",
+		Regex:      regexp.MustCompile(`Wordle\s+(?:` + numberPattern + `)\s+[X1-6]/6`),
+		Keywords:   []string{"wordle"},
+		Confidence: 1,
+	})
+	RegisterParser(Wordle, regexParser(
+		regexp.MustCompile(`Wordle\s+(?P<puzzle>`+numberPattern+`)\s+(?P<score>(?P<guesses>[X1-6])/6)(?P<hard>\*)?`),
+	))
+}
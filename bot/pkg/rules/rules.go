@@ -0,0 +1,324 @@
+package rules
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/togdon/reply-bot/bot/pkg/post"
+)
+
+const (
+	// defaultTextConfidence and defaultLinkConfidence match the built-in
+	// share and link detectors.
+	defaultTextConfidence = 1
+	defaultLinkConfidence = 0.8
+)
+
+// Rule is a detection rule read from a rules file, which holds a JSON array
+// of them in the same style as bsky-feeds.json:
+//
+//	[
+//	  {
+//	    "Name": "wordle-share",
+//	    "Type": "wordle",
+//	    "Regex": "Wordle\\s+[0-9,.]+\\s+[X1-6]/6",
+//	    "Keywords": ["wordle"]
+//	  },
+//	  {
+//	    "Name": "wirecutter-link",
+//	    "Type": "wirecutter",
+//	    "Domains": ["nytimes.com/wirecutter", "thewirecutter.com"]
+//	  },
+//	  {
+//	    "Name": "article-short-link",
+//	    "Enabled": false
+//	  },
+//	  {
+//	    "Name": "cooking-critics",
+//	    "Exclude": true,
+//	    "Type": "cooking",
+//	    "Keywords": ["overrated", "worst recipe"],
+//	    "Authors": ["@recipe-bot@example.social"]
+//	  }
+//	]
+//
+// A rule matches if Regex matches the text of a post or a link in it is on
+// one of Domains (a host, optionally followed by a path prefix). Keywords
+// are an optional prefilter: Regex only runs on text containing one of
+// them, ignoring case (see post.RegexDetector). Regex may not use named
+// groups, which built-in detectors use to pick the content type, so a
+// rule's posts are always detected as its Type. A rule with the same Name
+// as a built-in detector replaces it, or disables it if Enabled is false;
+// other rules are added after the built-in detectors.
+//
+// A rule with Exclude set is an exclusion instead (see post.Exclusion),
+// checked after detection: a detected post is dropped if its text contains
+// any of Keywords or matches Regex, it links to any of Domains, or its
+// author is one of Authors. Authors are handles or Bluesky DIDs; posts read
+// from Jetstream only carry their author's DID, so list a Bluesky account by
+// DID to exclude it there too. Type, if set, limits it to posts detected as
+// that type.
+type Rule struct {
+	Name       string
+	Type       post.NYTContentType
+	Regex      string
+	Keywords   []string
+	Domains    []string
+	Authors    []string
+	Exclude    bool
+	Enabled    *bool
+	Confidence float64
+}
+
+// enabled reports whether the rule is enabled; rules are unless they say
+// otherwise.
+func (r Rule) enabled() bool {
+	return r.Enabled == nil || *r.Enabled
+}
+
+// Load reads and validates the rules file at path.
+func Load(path string) ([]Rule, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read rules file: %w", err)
+	}
+
+	var rules []Rule
+	if err := json.Unmarshal(raw, &rules); err != nil {
+		return nil, fmt.Errorf("unable to parse rules file %s: %w", path, err)
+	}
+
+	names := make(map[string]bool)
+	for i, r := range rules {
+		if r.Name == "" {
+			return nil, fmt.Errorf("rule %d in %s has no Name", i+1, path)
+		}
+		if names[r.Name] {
+			return nil, fmt.Errorf("rule %q appears more than once in %s", r.Name, path)
+		}
+		names[r.Name] = true
+
+		if !r.enabled() {
+			continue
+		}
+		if r.Exclude {
+			_, err = r.exclusion()
+		} else {
+			_, err = r.detector()
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid rule %q in %s: %w", r.Name, path, err)
+		}
+	}
+
+	return rules, nil
+}
+
+// Apply returns builtins with rules applied: rules sharing a built-in
+// detector's name replace or disable it in place, and the remaining enabled
+// rules are appended in file order.
+func Apply(builtins []post.Detector, rules []Rule) ([]post.Detector, error) {
+	byName := make(map[string]Rule, len(rules))
+	for _, r := range rules {
+		if !r.Exclude {
+			byName[r.Name] = r
+		}
+	}
+
+	var detectors []post.Detector
+	for _, d := range builtins {
+		r, ok := byName[d.Name()]
+		if !ok {
+			detectors = append(detectors, d)
+			continue
+		}
+		delete(byName, r.Name)
+		if !r.enabled() {
+			continue
+		}
+		rd, err := r.detector()
+		if err != nil {
+			return nil, fmt.Errorf("invalid rule %q: %w", r.Name, err)
+		}
+		detectors = append(detectors, rd)
+	}
+
+	for _, r := range rules {
+		if _, ok := byName[r.Name]; !ok || !r.enabled() {
+			continue
+		}
+		rd, err := r.detector()
+		if err != nil {
+			return nil, fmt.Errorf("invalid rule %q: %w", r.Name, err)
+		}
+		detectors = append(detectors, rd)
+	}
+
+	return detectors, nil
+}
+
+// Exclusions returns the enabled exclusions among rules, in file order.
+func Exclusions(rules []Rule) ([]post.Exclusion, error) {
+	var exclusions []post.Exclusion
+	for _, r := range rules {
+		if !r.Exclude || !r.enabled() {
+			continue
+		}
+		e, err := r.exclusion()
+		if err != nil {
+			return nil, fmt.Errorf("invalid rule %q: %w", r.Name, err)
+		}
+		exclusions = append(exclusions, e)
+	}
+	return exclusions, nil
+}
+
+// exclusion validates an enabled exclusion rule and builds it.
+func (r Rule) exclusion() (post.Exclusion, error) {
+	e := post.Exclusion{Name: r.Name, Authors: r.Authors}
+
+	if r.Type != "" {
+		if !slices.Contains(post.ContentTypes(), r.Type) {
+			return e, fmt.Errorf("unknown Type %q", r.Type)
+		}
+		e.Types = []post.NYTContentType{r.Type}
+	}
+	if r.Regex == "" && len(r.Keywords) == 0 && len(r.Domains) == 0 && len(r.Authors) == 0 {
+		return e, fmt.Errorf("one of Keywords, Regex, Domains or Authors is required")
+	}
+
+	var err error
+	if e.Keywords, err = keywords(r.Keywords); err != nil {
+		return e, err
+	}
+	if r.Regex != "" {
+		if e.Regex, err = regexp.Compile(r.Regex); err != nil {
+			return e, fmt.Errorf("invalid Regex: %w", err)
+		}
+	}
+	if len(r.Domains) > 0 {
+		if e.URLRegex, err = domainsRegex(r.Domains); err != nil {
+			return e, err
+		}
+	}
+	for _, a := range r.Authors {
+		if strings.TrimSpace(strings.TrimPrefix(a, "@")) == "" {
+			return e, fmt.Errorf("empty entry in Authors")
+		}
+	}
+	return e, nil
+}
+
+// keywords lowercases keywords for matching.
+func keywords(in []string) ([]string, error) {
+	var out []string
+	for _, k := range in {
+		if k = strings.ToLower(strings.TrimSpace(k)); k == "" {
+			return nil, fmt.Errorf("empty entry in Keywords")
+		}
+		out = append(out, k)
+	}
+	return out, nil
+}
+
+// detector validates an enabled rule and builds the detector for it.
+func (r Rule) detector() (post.Detector, error) {
+	if !slices.Contains(post.ContentTypes(), r.Type) {
+		return nil, fmt.Errorf("unknown Type %q", r.Type)
+	}
+	if r.Regex == "" && len(r.Domains) == 0 {
+		return nil, fmt.Errorf("one of Regex or Domains is required")
+	}
+	if r.Confidence < 0 || r.Confidence > 1 {
+		return nil, fmt.Errorf("Confidence %v is not between 0 and 1", r.Confidence)
+	}
+
+	d := detector{name: r.Name}
+
+	if r.Regex != "" {
+		re, err := regexp.Compile(r.Regex)
+		if err != nil {
+			return nil, fmt.Errorf("invalid Regex: %w", err)
+		}
+		for _, name := range re.SubexpNames() {
+			if name != "" {
+				return nil, fmt.Errorf("Regex has a named group %q; use (?:...) or an unnamed group", name)
+			}
+		}
+		kw, err := keywords(r.Keywords)
+		if err != nil {
+			return nil, err
+		}
+		d.parts = append(d.parts, post.RegexDetector{
+			Type:       r.Type,
+			Rule:       r.Name,
+			Regex:      re,
+			Keywords:   kw,
+			Confidence: confidence(r.Confidence, defaultTextConfidence),
+		})
+	}
+
+	if len(r.Domains) > 0 {
+		re, err := domainsRegex(r.Domains)
+		if err != nil {
+			return nil, err
+		}
+		d.parts = append(d.parts, post.URLDetector{
+			Type:       r.Type,
+			Rule:       r.Name,
+			Regex:      re,
+			Confidence: confidence(r.Confidence, defaultLinkConfidence),
+		})
+	}
+
+	if len(d.parts) == 1 {
+		return d.parts[0], nil
+	}
+	return d, nil
+}
+
+func confidence(c, def float64) float64 {
+	if c == 0 {
+		return def
+	}
+	return c
+}
+
+// domainsRegex builds an expression matching canonical links (see
+// post.CanonicalURL) on any of domains.
+func domainsRegex(domains []string) (*regexp.Regexp, error) {
+	var alternatives []string
+	for _, d := range domains {
+		d = strings.ToLower(strings.TrimSpace(d))
+		d = strings.TrimPrefix(d, "https://")
+		d = strings.TrimPrefix(d, "http://")
+		d = strings.TrimPrefix(d, "www.")
+		d = strings.TrimSuffix(d, "/")
+		if d == "" {
+			return nil, fmt.Errorf("empty entry in Domains")
+		}
+		alternatives = append(alternatives, regexp.QuoteMeta(d))
+	}
+	return regexp.Compile(`^https://(?:` + strings.Join(alternatives, "|") + `)(?:/|$)`)
+}
+
+// detector is a rule with both a Regex and Domains, matching on either.
+type detector struct {
+	name  string
+	parts []post.Detector
+}
+
+func (d detector) Detect(c post.Candidate) (post.Match, bool) {
+	for _, p := range d.parts {
+		if m, ok := p.Detect(c); ok {
+			return m, true
+		}
+	}
+	return post.Match{}, false
+}
+
+func (d detector) Name() string { return d.name }
@@ -0,0 +1,183 @@
+package rules
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"testing"
+
+	"github.com/togdon/reply-bot/bot/pkg/post"
+)
+
+var builtins = []post.Detector{
+	post.RegexDetector{Type: post.Wordle, Rule: "wordle-share", Regex: regexp.MustCompile(`Wordle \d`), Confidence: 1},
+	post.URLDetector{Type: post.Cooking, Rule: "cooking-link", Regex: regexp.MustCompile(`cooking\.nytimes\.com`), Confidence: 0.8},
+	post.RegexDetector{Type: post.Strands, Rule: "strands-share", Regex: regexp.MustCompile(`Strands #\d`), Confidence: 1},
+}
+
+func writeRules(t *testing.T, dir, rules string) string {
+	t.Helper()
+	path := filepath.Join(dir, "rules.json")
+	if err := os.WriteFile(path, []byte(rules), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadInvalid(t *testing.T) {
+	tests := []struct {
+		name  string
+		rules string
+	}{
+		{"not json", `{`},
+		{"no name", `[{"Type": "wordle", "Regex": "Wordle"}]`},
+		{"duplicate name", `[{"Name": "a", "Type": "wordle", "Regex": "Wordle"}, {"Name": "a", "Type": "wordle", "Regex": "Wordle"}]`},
+		{"unknown type", `[{"Name": "a", "Type": "chess", "Regex": "Chess"}]`},
+		{"nothing to match", `[{"Name": "a", "Type": "wordle"}]`},
+		{"invalid regex", `[{"Name": "a", "Type": "wordle", "Regex": "Wordle ("}]`},
+		{"named group", `[{"Name": "a", "Type": "wordle", "Regex": "Wordle (?P<puzzle>\\d+)"}]`},
+		{"confidence out of range", `[{"Name": "a", "Type": "wordle", "Regex": "Wordle", "Confidence": 2}]`},
+		{"empty domain", `[{"Name": "a", "Type": "cooking", "Domains": [" "]}]`},
+		{"exclusion with nothing to match", `[{"Name": "a", "Exclude": true, "Type": "cooking"}]`},
+		{"exclusion with unknown type", `[{"Name": "a", "Exclude": true, "Type": "chess", "Keywords": ["x"]}]`},
+		{"exclusion with empty author", `[{"Name": "a", "Exclude": true, "Authors": ["@"]}]`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Load(writeRules(t, t.TempDir(), tt.rules)); err == nil {
+				t.Error("Load() error = nil, want an error")
+			}
+		})
+	}
+
+	// a disabled rule only needs a name
+	if _, err := Load(writeRules(t, t.TempDir(), `[{"Name": "strands-share", "Enabled": false}]`)); err != nil {
+		t.Errorf("Load() disabled rule error = %v", err)
+	}
+}
+
+func TestExclusions(t *testing.T) {
+	rules, err := Load(writeRules(t, t.TempDir(), `[
+		{"Name": "cooking-link", "Exclude": true, "Type": "cooking", "Keywords": ["Overrated"], "Domains": ["example.com"]},
+		{"Name": "bots", "Exclude": true, "Authors": ["@wordlebot@example.social"], "Regex": "(?i)spoiler"},
+		{"Name": "off", "Exclude": true, "Keywords": ["x"], "Enabled": false}
+	]`))
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	// an exclusion sharing a built-in detector's name leaves it alone
+	detectors, err := Apply(builtins, rules)
+	if err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	if len(detectors) != len(builtins) {
+		t.Errorf("Apply() returned %d detectors, want the %d built-ins", len(detectors), len(builtins))
+	}
+
+	exclusions, err := Exclusions(rules)
+	if err != nil {
+		t.Fatalf("Exclusions() error = %v", err)
+	}
+	r := post.NewRegistry()
+	r.SetExclusions(exclusions...)
+
+	tests := []struct {
+		name      string
+		candidate post.Candidate
+		match     post.Match
+		want      string
+	}{
+		{"keyword", post.Candidate{Text: "so overrated"}, post.Match{Type: post.Cooking}, "cooking-link"},
+		{"keyword for another type", post.Candidate{Text: "so overrated"}, post.Match{Type: post.Wordle}, ""},
+		{"domain", post.Candidate{URLs: []string{"https://example.com/recipe"}}, post.Match{Type: post.Cooking}, "cooking-link"},
+		{"author", post.Candidate{Author: "WordleBot@example.social"}, post.Match{Type: post.Wordle}, "bots"},
+		{"regex", post.Candidate{Text: "Spoilers ahead"}, post.Match{Type: post.Strands}, "bots"},
+		{"disabled", post.Candidate{Text: "x"}, post.Match{Type: post.Strands}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if name, _, _ := r.Exclude(tt.candidate, tt.match); name != tt.want {
+				t.Errorf("Exclude() = %q, want %q", name, tt.want)
+			}
+		})
+	}
+}
+
+func TestApply(t *testing.T) {
+	rules, err := Load(writeRules(t, t.TempDir(), `[
+		{"Name": "wordle-share", "Type": "wordle", "Regex": "Wordle\\s+[0-9,.]+\\s+[X1-6]/6", "Keywords": ["Wordle"]},
+		{"Name": "strands-share", "Enabled": false},
+		{"Name": "wirecutter-link", "Type": "wirecutter", "Domains": ["https://www.nytimes.com/wirecutter/", "thewirecutter.com"]},
+		{"Name": "sudoku-share", "Type": "sudoku", "Regex": "(?i)nyt sudoku", "Domains": ["nytimes.com/puzzles/sudoku"], "Confidence": 0.9},
+		{"Name": "unused", "Type": "wordle", "Regex": "Wordle", "Enabled": false}
+	]`))
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	detectors, err := Apply(builtins, rules)
+	if err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+
+	var names []string
+	for _, d := range detectors {
+		names = append(names, d.Name())
+	}
+	if want := []string{"wordle-share", "cooking-link", "wirecutter-link", "sudoku-share"}; !slices.Equal(names, want) {
+		t.Errorf("detectors = %v, want %v", names, want)
+	}
+
+	r := post.NewRegistry(detectors...)
+	tests := []struct {
+		name      string
+		candidate post.Candidate
+		want      post.Match
+	}{
+		{
+			name:      "overridden built-in",
+			candidate: post.Candidate{Text: "Wordle 1.236 4/6"},
+			want:      post.Match{Type: post.Wordle, Rule: "wordle-share", Confidence: 1},
+		},
+		{
+			name:      "disabled built-in",
+			candidate: post.Candidate{Text: "Strands #248"},
+		},
+		{
+			name:      "domain rule",
+			candidate: post.Candidate{URLs: []string{"https://thewirecutter.com/reviews/example/"}},
+			want:      post.Match{Type: post.Wirecutter, Rule: "wirecutter-link", Confidence: 0.8, URL: "https://thewirecutter.com/reviews/example/"},
+		},
+		{
+			name:      "domain with a path prefix",
+			candidate: post.Candidate{URLs: []string{"https://www.nytimes.com/wirecutter/reviews/example/"}},
+			want:      post.Match{Type: post.Wirecutter, Rule: "wirecutter-link", Confidence: 0.8, URL: "https://www.nytimes.com/wirecutter/reviews/example/"},
+		},
+		{
+			name:      "domain must match whole path segments",
+			candidate: post.Candidate{URLs: []string{"https://www.nytimes.com/wirecutterish/"}},
+		},
+		{
+			name:      "rule with regex and domains matches text",
+			candidate: post.Candidate{Text: "NYT Sudoku done"},
+			want:      post.Match{Type: post.Sudoku, Rule: "sudoku-share", Confidence: 0.9},
+		},
+		{
+			name:      "rule with regex and domains matches links",
+			candidate: post.Candidate{URLs: []string{"https://www.nytimes.com/puzzles/sudoku/hard"}},
+			want:      post.Match{Type: post.Sudoku, Rule: "sudoku-share", Confidence: 0.9, URL: "https://www.nytimes.com/puzzles/sudoku/hard"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := r.Detect(tt.candidate)
+			if ok != (tt.want != post.Match{}) || got != tt.want {
+				t.Errorf("Detect() = %v, %v, want %v", got, ok, tt.want)
+			}
+		})
+	}
+}
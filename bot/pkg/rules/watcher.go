@@ -0,0 +1,113 @@
+package rules
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/togdon/reply-bot/bot/pkg/post"
+)
+
+const defaultInterval = 30 * time.Second
+
+// Watcher applies a rules file to a registry and reapplies it whenever the
+// file changes or a reload is requested. A file that fails to load or
+// validate is reported and the rules already in place are kept, so a typo
+// can't stop detection.
+type Watcher struct {
+	Path     string
+	Interval time.Duration
+
+	registry *post.Registry
+	builtins []post.Detector
+	logger   *slog.Logger
+
+	modTime time.Time
+	size    int64
+}
+
+// NewWatcher applies the rules file at path to registry, remembering the
+// registry's current detectors as the built-ins the rules apply to. It
+// returns an error if the file can't be loaded, so bad rules are caught at
+// startup.
+func NewWatcher(logger *slog.Logger, path string, registry *post.Registry) (*Watcher, error) {
+	w := &Watcher{
+		Path:     path,
+		Interval: defaultInterval,
+		registry: registry,
+		builtins: registry.Detectors(),
+		logger:   logger,
+	}
+	if err := w.Load(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// Load reads the rules file and swaps the resulting detectors into the
+// registry.
+func (w *Watcher) Load() error {
+	info, err := os.Stat(w.Path)
+	if err != nil {
+		return fmt.Errorf("unable to read rules file: %w", err)
+	}
+	// remember the file even if it is invalid, so it is only reported once
+	w.modTime, w.size = info.ModTime(), info.Size()
+
+	rules, err := Load(w.Path)
+	if err != nil {
+		return err
+	}
+	detectors, err := Apply(w.builtins, rules)
+	if err != nil {
+		return err
+	}
+	exclusions, err := Exclusions(rules)
+	if err != nil {
+		return err
+	}
+
+	w.registry.Set(detectors...)
+	w.registry.SetExclusions(exclusions...)
+	w.logger.Info("Loaded detection rules", "path", w.Path, "rules", len(rules), "detectors", len(detectors), "exclusions", len(exclusions))
+	return nil
+}
+
+// Run polls the rules file every Interval and reloads it when its
+// modification time or size changes, or when reload receives a value (e.g.
+// SIGHUP), until ctx is cancelled.
+func (w *Watcher) Run(ctx context.Context, reload <-chan os.Signal) {
+	ticker := time.NewTicker(w.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if w.changed() {
+				w.reload("file changed")
+			}
+		case <-reload:
+			w.reload("reload requested")
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (w *Watcher) changed() bool {
+	info, err := os.Stat(w.Path)
+	if err != nil {
+		w.logger.Error("Unable to check rules file", "path", w.Path, "err", err)
+		return false
+	}
+	return !info.ModTime().Equal(w.modTime) || info.Size() != w.size
+}
+
+func (w *Watcher) reload(reason string) {
+	w.logger.Info("Reloading detection rules", "path", w.Path, "reason", reason)
+	if err := w.Load(); err != nil {
+		w.logger.Error("Unable to reload detection rules, keeping the current ones", "path", w.Path, "err", err)
+	}
+}
@@ -0,0 +1,81 @@
+package rules
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/togdon/reply-bot/bot/pkg/post"
+)
+
+func TestWatcher(t *testing.T) {
+	dir := t.TempDir()
+	path := writeRules(t, dir, `[{"Name": "strands-share", "Enabled": false}]`)
+
+	registry := post.NewRegistry(builtins...)
+	w, err := NewWatcher(slog.New(slog.NewTextHandler(io.Discard, nil)), path, registry)
+	if err != nil {
+		t.Fatalf("NewWatcher() error = %v", err)
+	}
+	w.Interval = 10 * time.Millisecond
+
+	detected := func(text string) bool {
+		_, ok := registry.Detect(post.Candidate{Text: text})
+		return ok
+	}
+	waitFor := func(what string, cond func() bool) {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for !cond() {
+			if time.Now().After(deadline) {
+				t.Fatalf("timed out waiting for %s", what)
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+	}
+
+	if detected("Strands #248") {
+		t.Fatal("strands-share should be disabled by the rules file")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	reload := make(chan os.Signal, 1)
+	go w.Run(ctx, reload)
+
+	// an invalid file keeps the current rules
+	writeRules(t, dir, `[{"Name": "strands-share", "Type": "chess", "Regex": "("}]`)
+	time.Sleep(50 * time.Millisecond)
+	if detected("Strands #248") {
+		t.Error("an invalid rules file should not replace the current rules")
+	}
+
+	// a valid change is picked up by polling
+	writeRules(t, dir, `[{"Name": "extra", "Type": "strands", "Regex": "Strands"}]`)
+	waitFor("the changed file to load", func() bool { return detected("Strands #248") && detected("Strands!") })
+
+	// and on request, even if the file looks unchanged
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(`[{"Name": "extrb", "Type": "strands", "Regex": "Strands"}]`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, info.ModTime(), info.ModTime()); err != nil {
+		t.Fatal(err)
+	}
+	reload <- syscall.SIGHUP
+	waitFor("the reload", func() bool {
+		for _, d := range registry.Detectors() {
+			if d.Name() == "extrb" {
+				return true
+			}
+		}
+		return false
+	})
+}
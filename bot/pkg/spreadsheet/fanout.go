@@ -0,0 +1,183 @@
+package spreadsheet
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/togdon/reply-bot/bot/pkg/post"
+)
+
+var _ Sink = (*FanOut)(nil)
+
+// NamedSink pairs a sink with the name used to report on it.
+type NamedSink struct {
+	Name string
+	Sink Sink
+}
+
+// Result is the outcome of delivering one post to one sink.
+type Result struct {
+	Sink string
+	Err  error
+}
+
+// Results is the outcome of delivering one post to every sink of a FanOut.
+type Results []Result
+
+// Err joins the errors of every sink that failed, or returns nil if they
+// all succeeded.
+func (r Results) Err() error {
+	var errs []error
+	for _, res := range r {
+		if res.Err != nil {
+			errs = append(errs, fmt.Errorf("sink %s: %w", res.Sink, res.Err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// PartialError is returned by FanOut.Write when some sinks accepted the
+// post and the others did not.
+type PartialError struct {
+	Results Results
+}
+
+func (e *PartialError) Error() string { return e.Results.Err().Error() }
+
+func (e *PartialError) Unwrap() error { return e.Results.Err() }
+
+// Accepted reports whether a post whose write returned err was still stored
+// by at least one sink: err is nil or a *PartialError.
+func Accepted(err error) bool {
+	var partial *PartialError
+	return err == nil || errors.As(err, &partial)
+}
+
+// SinkStats counts the deliveries made to a single sink.
+type SinkStats struct {
+	Succeeded uint64
+	Failed    uint64
+	LastErr   error
+}
+
+// FanOut delivers each post to several sinks concurrently. Every sink gets
+// its own timeout, so one that is failing or slow (e.g. Sheets returning
+// quota errors) does not drop the writes to the others.
+//
+// Write still waits for every sink, so a hung sink holds up the caller for
+// up to the timeout. Callers that must not wait on the slowest sink put each
+// sink behind its own outbox.Outbox, whose Write only records the post on
+// disk.
+type FanOut struct {
+	sinks   []NamedSink
+	timeout time.Duration
+	logger  *slog.Logger
+
+	mu    sync.Mutex
+	stats map[string]SinkStats
+}
+
+// NewFanOut returns a FanOut over sinks. A timeout of zero leaves each
+// write bounded only by the caller's context.
+func NewFanOut(logger *slog.Logger, timeout time.Duration, sinks ...NamedSink) *FanOut {
+	return &FanOut{
+		sinks:   sinks,
+		timeout: timeout,
+		logger:  logger,
+		stats:   make(map[string]SinkStats, len(sinks)),
+	}
+}
+
+// Write implements Sink. It returns an error naming every sink that failed;
+// the post has still been delivered to all the others, and if there are any
+// the error is a *PartialError.
+func (f *FanOut) Write(ctx context.Context, p post.Post) error {
+	results := f.WriteAll(ctx, p)
+	err := results.Err()
+	if err == nil {
+		return nil
+	}
+	for _, res := range results {
+		if res.Err == nil {
+			return &PartialError{Results: results}
+		}
+	}
+	return err
+}
+
+// WriteAll delivers p to every sink concurrently and reports the outcome
+// for each one, in the order the sinks were given to NewFanOut.
+func (f *FanOut) WriteAll(ctx context.Context, p post.Post) Results {
+	results := make(Results, len(f.sinks))
+
+	var wg sync.WaitGroup
+	for i, s := range f.sinks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = Result{Sink: s.Name, Err: f.write(ctx, s.Sink, p)}
+		}()
+	}
+	wg.Wait()
+
+	for _, res := range results {
+		f.record(res)
+		if res.Err != nil {
+			f.logger.Error("unable to write post to sink", "sink", res.Sink, "id", p.ID, "err", res.Err)
+		} else {
+			f.logger.Debug("Post written to sink", "sink", res.Sink, "id", p.ID)
+		}
+	}
+
+	return results
+}
+
+func (f *FanOut) write(ctx context.Context, sink Sink, p post.Post) error {
+	if f.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, f.timeout)
+		defer cancel()
+	}
+	return sink.Write(ctx, p)
+}
+
+func (f *FanOut) record(res Result) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	stats := f.stats[res.Sink]
+	if res.Err != nil {
+		stats.Failed++
+		stats.LastErr = res.Err
+	} else {
+		stats.Succeeded++
+	}
+	f.stats[res.Sink] = stats
+}
+
+// Stats returns the delivery counts of every sink that has been written to.
+func (f *FanOut) Stats() map[string]SinkStats {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	stats := make(map[string]SinkStats, len(f.stats))
+	for name, s := range f.stats {
+		stats[name] = s
+	}
+	return stats
+}
+
+// Close implements Sink by closing every sink.
+func (f *FanOut) Close() error {
+	var errs []error
+	for _, s := range f.sinks {
+		if err := s.Sink.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("sink %s: %w", s.Name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,108 @@
+package spreadsheet
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/togdon/reply-bot/bot/pkg/post"
+)
+
+type fakeSink struct {
+	mu     sync.Mutex
+	posts  []post.Post
+	err    error
+	block  bool
+	closed bool
+}
+
+func (f *fakeSink) Write(ctx context.Context, p post.Post) error {
+	if f.block {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	if f.err != nil {
+		return f.err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.posts = append(f.posts, p)
+	return nil
+}
+
+func (f *fakeSink) Close() error {
+	f.closed = true
+	return nil
+}
+
+func TestFanOut(t *testing.T) {
+	quotaErr := errors.New("quota exceeded")
+
+	var (
+		healthy = &fakeSink{}
+		failing = &fakeSink{err: quotaErr}
+		hung    = &fakeSink{block: true}
+	)
+	f := NewFanOut(slog.New(slog.NewTextHandler(io.Discard, nil)), 50*time.Millisecond,
+		NamedSink{Name: "healthy", Sink: healthy},
+		NamedSink{Name: "failing", Sink: failing},
+		NamedSink{Name: "hung", Sink: hung},
+	)
+
+	p := post.Post{ID: "1", URI: "https://example.com/1", Content: "Wordle 1,236 4/6", Source: post.Mastodon, Type: post.Wordle}
+
+	start := time.Now()
+	results := f.WriteAll(context.Background(), p)
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("WriteAll() took %v, a hung sink should only hold it for the timeout", elapsed)
+	}
+
+	if len(results) != 3 {
+		t.Fatalf("WriteAll() returned %d results, want 3", len(results))
+	}
+	if results[0].Err != nil {
+		t.Errorf("healthy sink error = %v, want nil", results[0].Err)
+	}
+	if !errors.Is(results[1].Err, quotaErr) {
+		t.Errorf("failing sink error = %v, want %v", results[1].Err, quotaErr)
+	}
+	if !errors.Is(results[2].Err, context.DeadlineExceeded) {
+		t.Errorf("hung sink error = %v, want %v", results[2].Err, context.DeadlineExceeded)
+	}
+	if !errors.Is(results.Err(), quotaErr) {
+		t.Errorf("Results.Err() = %v, want it to wrap %v", results.Err(), quotaErr)
+	}
+
+	if err := f.Write(context.Background(), p); !errors.Is(err, quotaErr) || !Accepted(err) {
+		t.Errorf("Write() error = %v, want a partial failure wrapping %v", err, quotaErr)
+	}
+	if err := NewFanOut(f.logger, 0, NamedSink{Name: "failing", Sink: failing}).Write(context.Background(), p); Accepted(err) {
+		t.Errorf("Write() error = %v, want a failure when no sink accepted the post", err)
+	}
+
+	if !reflect.DeepEqual(healthy.posts, []post.Post{p, p}) {
+		t.Errorf("healthy sink received %v, want %v", healthy.posts, []post.Post{p, p})
+	}
+
+	stats := f.Stats()
+	if got := stats["healthy"]; got.Succeeded != 2 || got.Failed != 0 {
+		t.Errorf("healthy stats = %+v, want 2 succeeded", got)
+	}
+	if got := stats["failing"]; got.Succeeded != 0 || got.Failed != 2 || !errors.Is(got.LastErr, quotaErr) {
+		t.Errorf("failing stats = %+v, want 2 failed with %v", got, quotaErr)
+	}
+
+	if err := f.Close(); err != nil {
+		t.Errorf("Close() error = %v", err)
+	}
+	for _, s := range []*fakeSink{healthy, failing, hung} {
+		if !s.closed {
+			t.Errorf("sink %p was not closed", s)
+		}
+	}
+}
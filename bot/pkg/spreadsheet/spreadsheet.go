@@ -1 +1,39 @@
-package spreadsheet
\ No newline at end of file
+package spreadsheet
+
+import (
+	"context"
+
+	"github.com/togdon/reply-bot/bot/pkg/post"
+)
+
+// Sink is a destination for detected posts. The gsheets client is the
+// original implementation, but anything that can store a post.Post (or a
+// fake in tests) can be plugged into the mastodon and bsky clients.
+type Sink interface {
+	// Write stores a single post, returning an error if it could not be
+	// persisted.
+	Write(ctx context.Context, p post.Post) error
+	// Close flushes any buffered state and releases the sink's resources.
+	Close() error
+}
+
+// BatchSink is a Sink that can store several posts more cheaply than
+// writing them one at a time, e.g. in a single API call.
+type BatchSink interface {
+	Sink
+	// WriteBatch stores every post, returning an error if any of them could
+	// not be persisted. If some of them were, the error is a *BatchError
+	// saying which, so they are not written again.
+	WriteBatch(ctx context.Context, posts []post.Post) error
+}
+
+// BatchError is returned by WriteBatch when only some of the posts were
+// stored. Written holds the indexes of those posts in the batch.
+type BatchError struct {
+	Written []int
+	Err     error
+}
+
+func (e *BatchError) Error() string { return e.Err.Error() }
+
+func (e *BatchError) Unwrap() error { return e.Err }
@@ -0,0 +1,83 @@
+package sqlite
+
+import (
+	"context"
+	"fmt"
+)
+
+// migrations are applied in order and recorded in schema_migrations; the
+// version of a migration is its index plus one. Never edit or reorder an
+// existing entry, only append new ones.
+var migrations = []string{
+	`CREATE TABLE posts (
+		source     TEXT NOT NULL,
+		id         TEXT NOT NULL,
+		uri        TEXT NOT NULL,
+		type       TEXT NOT NULL,
+		content    TEXT NOT NULL,
+		first_seen TIMESTAMP NOT NULL,
+		PRIMARY KEY (source, id)
+	)`,
+	`CREATE INDEX posts_type_idx ON posts (type);
+	 CREATE INDEX posts_first_seen_idx ON posts (first_seen)`,
+	`CREATE TABLE seen (
+		key        TEXT PRIMARY KEY,
+		first_seen TIMESTAMP NOT NULL
+	)`,
+	`ALTER TABLE posts ADD COLUMN puzzle INTEGER;
+	 ALTER TABLE posts ADD COLUMN puzzle_date TEXT;
+	 ALTER TABLE posts ADD COLUMN score TEXT;
+	 ALTER TABLE posts ADD COLUMN guesses INTEGER;
+	 ALTER TABLE posts ADD COLUMN mistakes INTEGER;
+	 ALTER TABLE posts ADD COLUMN solved INTEGER;
+	 ALTER TABLE posts ADD COLUMN hard_mode INTEGER;
+	 ALTER TABLE posts ADD COLUMN solve_seconds INTEGER;
+	 ALTER TABLE posts ADD COLUMN link_url TEXT;
+	 ALTER TABLE posts ADD COLUMN link_section TEXT;
+	 CREATE INDEX posts_type_puzzle_idx ON posts (type, puzzle)`,
+}
+
+// migrate applies every migration newer than the database's current
+// schema version, each in its own transaction.
+func (c *Client) migrate(ctx context.Context) error {
+	if _, err := c.db.ExecContext(ctx,
+		`CREATE TABLE IF NOT EXISTS schema_migrations (
+			version    INTEGER PRIMARY KEY,
+			applied_at TIMESTAMP NOT NULL
+		)`,
+	); err != nil {
+		return fmt.Errorf("unable to create schema_migrations table: %w", err)
+	}
+
+	var current int
+	if err := c.db.QueryRowContext(ctx, "SELECT COALESCE(MAX(version), 0) FROM schema_migrations").Scan(&current); err != nil {
+		return fmt.Errorf("unable to read schema version: %w", err)
+	}
+
+	for i := current; i < len(migrations); i++ {
+		version := i + 1
+
+		tx, err := c.db.BeginTx(ctx, nil)
+		if err != nil {
+			return fmt.Errorf("unable to begin migration %d: %w", version, err)
+		}
+		if _, err := tx.ExecContext(ctx, migrations[i]); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("unable to apply migration %d: %w", version, err)
+		}
+		if _, err := tx.ExecContext(ctx,
+			"INSERT INTO schema_migrations (version, applied_at) VALUES (?, ?)",
+			version, c.now().UTC(),
+		); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("unable to record migration %d: %w", version, err)
+		}
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("unable to commit migration %d: %w", version, err)
+		}
+
+		c.logger.Info("Applied sqlite migration", "version", version)
+	}
+
+	return nil
+}
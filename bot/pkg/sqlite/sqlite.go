@@ -0,0 +1,183 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+
+	"github.com/togdon/reply-bot/bot/pkg/post"
+	"github.com/togdon/reply-bot/bot/pkg/spreadsheet"
+)
+
+var _ spreadsheet.Sink = (*Client)(nil)
+
+// Client stores detected posts in a local SQLite database so their history
+// can be queried.
+type Client struct {
+	db     *sql.DB
+	logger *slog.Logger
+	now    func() time.Time
+}
+
+// NewClient opens (creating if needed) the database at path and brings its
+// schema up to date before returning.
+func NewClient(ctx context.Context, logger *slog.Logger, path string) (*Client, error) {
+	db, err := sql.Open("sqlite3", fmt.Sprintf("file:%s?_busy_timeout=5000&_journal_mode=WAL", path))
+	if err != nil {
+		return nil, fmt.Errorf("unable to open sqlite database: %w", err)
+	}
+	// SQLite only supports a single writer; serialising through one
+	// connection avoids "database is locked" errors between goroutines.
+	db.SetMaxOpenConns(1)
+
+	c := &Client{
+		db:     db,
+		logger: logger,
+		now:    time.Now,
+	}
+
+	if err := c.migrate(ctx); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// Write implements spreadsheet.Sink. A post that has already been stored
+// for the same source keeps its original first-seen timestamp.
+func (c *Client) Write(ctx context.Context, p post.Post) error {
+	args := []interface{}{p.Source, p.ID, p.URI, p.Type, p.Content, c.now().UTC()}
+	args = append(args, gameColumns(p.Game)...)
+	args = append(args, linkColumns(p.Link)...)
+
+	_, err := c.db.ExecContext(ctx,
+		`INSERT INTO posts (
+			source, id, uri, type, content, first_seen,
+			puzzle, puzzle_date, score, guesses, mistakes, solved, hard_mode, solve_seconds,
+			link_url, link_section
+		 )
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		 ON CONFLICT (source, id) DO NOTHING`,
+		args...,
+	)
+	if err != nil {
+		return fmt.Errorf("unable to insert post into sqlite: %w", err)
+	}
+
+	c.logger.Debug("Post stored in sqlite", "id", p.ID, "source", p.Source)
+	return nil
+}
+
+// Close implements spreadsheet.Sink.
+func (c *Client) Close() error {
+	return c.db.Close()
+}
+
+// Seen implements dedupe.Store, reporting whether key has been added.
+func (c *Client) Seen(ctx context.Context, key string) (bool, error) {
+	var n int
+	if err := c.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM seen WHERE key = ?", key).Scan(&n); err != nil {
+		return false, fmt.Errorf("unable to look up seen key: %w", err)
+	}
+	return n > 0, nil
+}
+
+// Add implements dedupe.Store, recording key as seen.
+func (c *Client) Add(ctx context.Context, key string) error {
+	_, err := c.db.ExecContext(ctx,
+		"INSERT INTO seen (key, first_seen) VALUES (?, ?) ON CONFLICT (key) DO NOTHING",
+		key, c.now().UTC(),
+	)
+	if err != nil {
+		return fmt.Errorf("unable to record seen key: %w", err)
+	}
+	return nil
+}
+
+// gameColumns returns the values of the game columns of the posts table,
+// all NULL for posts that aren't game shares and NULL for values the game
+// doesn't report. Mistakes and Solved are stored whenever they are set, as
+// no mistakes and a loss are results.
+func gameColumns(g *post.GameResult) []interface{} {
+	if g == nil {
+		return make([]interface{}, 8)
+	}
+	return []interface{}{
+		nullIfZero(g.Puzzle),
+		nullIfZero(g.Date),
+		nullIfZero(g.Score),
+		nullIfZero(g.Guesses),
+		nullIfNil(g.Mistakes),
+		nullIfNil(g.Solved),
+		g.HardMode,
+		nullIfZero(int(g.SolveTime.Seconds())),
+	}
+}
+
+// linkColumns returns the values of the link columns of the posts table.
+func linkColumns(l *post.Link) []interface{} {
+	if l == nil {
+		return make([]interface{}, 2)
+	}
+	return []interface{}{l.URL, nullIfZero(l.Section)}
+}
+
+func nullIfZero[T comparable](v T) interface{} {
+	var zero T
+	if v == zero {
+		return nil
+	}
+	return v
+}
+
+func nullIfNil[T any](v *T) interface{} {
+	if v == nil {
+		return nil
+	}
+	return *v
+}
+
+// CountByType returns the number of stored posts per post.NYTContentType.
+func (c *Client) CountByType(ctx context.Context) (map[string]int, error) {
+	return c.count(ctx, "type")
+}
+
+// CountBySource returns the number of stored posts per post.APISource.
+func (c *Client) CountBySource(ctx context.Context) (map[string]int, error) {
+	return c.count(ctx, "source")
+}
+
+// CountByDay returns the number of stored posts per UTC day (YYYY-MM-DD) in
+// which they were first seen.
+func (c *Client) CountByDay(ctx context.Context) (map[string]int, error) {
+	return c.count(ctx, "date(first_seen)")
+}
+
+// count groups the posts table by expr, which must be a trusted column or
+// expression and never user input.
+func (c *Client) count(ctx context.Context, expr string) (map[string]int, error) {
+	rows, err := c.db.QueryContext(ctx, fmt.Sprintf("SELECT %s, COUNT(*) FROM posts GROUP BY 1", expr))
+	if err != nil {
+		return nil, fmt.Errorf("unable to count posts by %s: %w", expr, err)
+	}
+	defer rows.Close()
+
+	counts := make(map[string]int)
+	for rows.Next() {
+		var (
+			key string
+			n   int
+		)
+		if err := rows.Scan(&key, &n); err != nil {
+			return nil, fmt.Errorf("unable to scan post counts: %w", err)
+		}
+		counts[key] = n
+	}
+
+	return counts, rows.Err()
+}
@@ -0,0 +1,169 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"io"
+	"log/slog"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/togdon/reply-bot/bot/pkg/post"
+)
+
+func newTestClient(t *testing.T) *Client {
+	t.Helper()
+	c, err := NewClient(context.Background(), slog.New(slog.NewTextHandler(io.Discard, nil)), filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+func TestMigrateIsIdempotent(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	for i := 0; i < 2; i++ {
+		c, err := NewClient(context.Background(), logger, path)
+		if err != nil {
+			t.Fatalf("NewClient() run %d error = %v", i, err)
+		}
+
+		var version int
+		if err := c.db.QueryRow("SELECT MAX(version) FROM schema_migrations").Scan(&version); err != nil {
+			t.Fatalf("reading schema version: %v", err)
+		}
+		if version != len(migrations) {
+			t.Errorf("schema version = %d, want %d", version, len(migrations))
+		}
+		c.Close()
+	}
+}
+
+func TestWrite(t *testing.T) {
+	c := newTestClient(t)
+	ctx := context.Background()
+
+	day1 := time.Date(2024, 11, 6, 9, 0, 0, 0, time.UTC)
+	day2 := day1.Add(24 * time.Hour)
+
+	writes := []struct {
+		at   time.Time
+		post post.Post
+	}{
+		{day1, post.Post{ID: "1", URI: "https://example.com/1", Content: "Wordle 1,236 4/6", Source: post.Mastodon, Type: post.Wordle}},
+		{day1, post.Post{ID: "2", URI: "https://example.com/2", Content: "Strands #248", Source: post.BlueSky, Type: post.Strands}},
+		// same ID from a different source is a different post
+		{day2, post.Post{ID: "1", URI: "https://example.com/3", Content: "Wordle 1,237 3/6", Source: post.BlueSky, Type: post.Wordle}},
+		// a repeat of the first post must not be counted again
+		{day2, post.Post{ID: "1", URI: "https://example.com/1", Content: "Wordle 1,236 4/6", Source: post.Mastodon, Type: post.Wordle}},
+	}
+	for _, w := range writes {
+		c.now = func() time.Time { return w.at }
+		if err := c.Write(ctx, w.post); err != nil {
+			t.Fatalf("Write(%v) error = %v", w.post, err)
+		}
+	}
+
+	tests := []struct {
+		name  string
+		count func(context.Context) (map[string]int, error)
+		want  map[string]int
+	}{
+		{"by type", c.CountByType, map[string]int{"wordle": 2, "strands": 1}},
+		{"by source", c.CountBySource, map[string]int{"mastodon": 1, "bluesky": 2}},
+		{"by day", c.CountByDay, map[string]int{"2024-11-06": 2, "2024-11-07": 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.count(ctx)
+			if err != nil {
+				t.Fatalf("count error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("count = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWriteMetadata(t *testing.T) {
+	c := newTestClient(t)
+	ctx := context.Background()
+
+	solved := false
+	posts := []post.Post{
+		{
+			ID: "1", URI: "https://example.com/1", Content: "Wordle 1,236 X/6", Source: post.Mastodon, Type: post.Wordle,
+			Game: &post.GameResult{Puzzle: 1236, Date: "2024-11-06", Score: "X/6", Guesses: 6, Solved: &solved, HardMode: true},
+		},
+		{
+			ID: "2", URI: "https://example.com/2", Content: "dinner", Source: post.Mastodon, Type: post.Cooking,
+			Link: &post.Link{URL: "https://cooking.nytimes.com/recipes/1", Section: "recipes"},
+		},
+	}
+	for _, p := range posts {
+		if err := c.Write(ctx, p); err != nil {
+			t.Fatalf("Write(%v) error = %v", p, err)
+		}
+	}
+
+	type row struct {
+		Puzzle, Guesses, Mistakes, SolveSeconds sql.NullInt64
+		Solved, HardMode                        sql.NullBool
+		Date, Score, LinkURL, LinkSection       sql.NullString
+	}
+	want := map[string]row{
+		"1": {
+			Puzzle:   sql.NullInt64{Int64: 1236, Valid: true},
+			Guesses:  sql.NullInt64{Int64: 6, Valid: true},
+			Solved:   sql.NullBool{Valid: true},
+			HardMode: sql.NullBool{Bool: true, Valid: true},
+			Date:     sql.NullString{String: "2024-11-06", Valid: true},
+			Score:    sql.NullString{String: "X/6", Valid: true},
+		},
+		"2": {
+			LinkURL:     sql.NullString{String: "https://cooking.nytimes.com/recipes/1", Valid: true},
+			LinkSection: sql.NullString{String: "recipes", Valid: true},
+		},
+	}
+
+	for id, w := range want {
+		var got row
+		err := c.db.QueryRowContext(ctx,
+			`SELECT puzzle, guesses, mistakes, solve_seconds, solved, hard_mode, puzzle_date, score, link_url, link_section
+			 FROM posts WHERE id = ?`, id,
+		).Scan(&got.Puzzle, &got.Guesses, &got.Mistakes, &got.SolveSeconds, &got.Solved, &got.HardMode, &got.Date, &got.Score, &got.LinkURL, &got.LinkSection)
+		if err != nil {
+			t.Fatalf("reading post %s: %v", id, err)
+		}
+		if got != w {
+			t.Errorf("post %s = %+v, want %+v", id, got, w)
+		}
+	}
+}
+
+func TestSeen(t *testing.T) {
+	c := newTestClient(t)
+	ctx := context.Background()
+
+	seen, err := c.Seen(ctx, "bluesky:abc")
+	if err != nil || seen {
+		t.Fatalf("Seen() = %v, %v, want false before Add", seen, err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := c.Add(ctx, "bluesky:abc"); err != nil {
+			t.Fatalf("Add() error = %v", err)
+		}
+	}
+
+	seen, err = c.Seen(ctx, "bluesky:abc")
+	if err != nil || !seen {
+		t.Errorf("Seen() = %v, %v, want true after Add", seen, err)
+	}
+}